@@ -0,0 +1,232 @@
+package testparrot
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+
+	. "github.com/dave/jennifer/jen"
+)
+
+const (
+	// BlobDir defines directory, relative to package directory, where large
+	// values are stored
+	BlobDir = "testdata/testparrot/blobs"
+
+	blobF = "Blob"
+
+	// gzipSuffix defines suffix of compressed blob files
+	gzipSuffix = ".gz"
+)
+
+// blobOptions defines how generator stores large values in blob files
+type blobOptions struct {
+	// root defines package directory, where blob directory is created
+	root string
+
+	// threshold defines size in bytes, from which values are stored in
+	// blob files
+	threshold int
+
+	// compress defines whether blob files are compressed with gzip
+	compress bool
+}
+
+// SetBlobs makes generator store string and byte slice values, which are at
+// least threshold bytes large, in content addressed blob files in BlobDir of
+// package directory root, instead of inlining them into generated code.
+// Generated code loads values from blob files using Blob function. Blob files
+// are optionally compressed with gzip. Zero threshold disables blob files.
+func (g *Generator) SetBlobs(root string, threshold int, compress bool) {
+	if threshold <= 0 {
+		g.blobs = nil
+		return
+	}
+
+	g.blobs = &blobOptions{root: root, threshold: threshold, compress: compress}
+}
+
+// blobToCode returns code that loads data from blob file, if data is large
+// enough to be stored in a blob file. Blob file is written when generated
+// code is written.
+func blobToCode(g *Generator, data []byte) (*Statement, error) {
+	if g.blobs == nil || len(data) < g.blobs.threshold {
+		return nil, nil
+	}
+
+	sum := sha256.Sum256(data)
+	name := hex.EncodeToString(sum[:])
+
+	if g.blobs.compress {
+		name += gzipSuffix
+
+		buf := &bytes.Buffer{}
+		w := gzip.NewWriter(buf)
+		if _, err := w.Write(data); err != nil {
+			return nil, err
+		}
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+
+		data = buf.Bytes()
+	}
+
+	if g.pendingBlobs == nil {
+		g.pendingBlobs = map[string][]byte{}
+	}
+	g.pendingBlobs[name] = data
+
+	return Qual(pkgPath, blobF).Call(Lit(path.Join(BlobDir, name))), nil
+}
+
+// writeBlobs writes blob files of values in generated code
+func (g *Generator) writeBlobs() error {
+	if len(g.pendingBlobs) == 0 {
+		return nil
+	}
+
+	dir := filepath.Join(g.blobs.root, filepath.FromSlash(BlobDir))
+	if err := os.MkdirAll(dir, 0770); err != nil {
+		return err
+	}
+
+	for name, data := range g.pendingBlobs {
+		blobPath := filepath.Join(dir, name)
+
+		// blob files are content addressed, so existing files are up to date
+		if _, err := os.Stat(blobPath); err == nil {
+			continue
+		}
+
+		if err := ioutil.WriteFile(blobPath, data, 0660); err != nil {
+			return err
+		}
+	}
+
+	g.pendingBlobs = nil
+
+	return nil
+}
+
+// missingBlobs returns paths of blob files of values in generated code,
+// which do not exist
+func (g *Generator) missingBlobs() []string {
+	missing := []string{}
+	for name := range g.pendingBlobs {
+		blobPath := filepath.Join(g.blobs.root, filepath.FromSlash(BlobDir), name)
+		if _, err := os.Stat(blobPath); err != nil {
+			missing = append(missing, path.Join(BlobDir, name))
+		}
+	}
+
+	sort.Strings(missing)
+
+	return missing
+}
+
+// removeUnusedBlobs removes blob files in BlobDir of package directory dir,
+// which are not referenced by any generated or pending file in dir
+func removeUnusedBlobs(dir string) error {
+	blobDir := filepath.Join(dir, filepath.FromSlash(BlobDir))
+	infos, err := ioutil.ReadDir(blobDir)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	sources := [][]byte{}
+	for _, file := range files {
+		if file.IsDir() || !(strings.HasSuffix(file.Name(), ".go") || strings.HasSuffix(file.Name(), PendingFileSuffix)) {
+			continue
+		}
+
+		src, err := ioutil.ReadFile(filepath.Join(dir, file.Name()))
+		if err != nil {
+			return err
+		}
+
+		sources = append(sources, src)
+	}
+
+	for _, info := range infos {
+		if info.IsDir() {
+			continue
+		}
+
+		// blob paths are written to generated code as string literals
+		ref := []byte(strconv.Quote(path.Join(BlobDir, info.Name())))
+
+		used := false
+		for _, src := range sources {
+			if bytes.Contains(src, ref) {
+				used = true
+				break
+			}
+		}
+
+		if used {
+			continue
+		}
+
+		if err := os.Remove(filepath.Join(blobDir, info.Name())); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Blob reads value stored in blob file by generated code. Path is relative to
+// directory of generated file calling Blob, or to working directory if source
+// file paths are not available, like when built with -trimpath. Blob files
+// with .gz suffix are decompressed. Blob panics if file cannot be read, as it
+// is called from init functions of generated files.
+func Blob(path string) []byte {
+	dir := ""
+	if _, file, _, ok := runtime.Caller(1); ok && filepath.IsAbs(file) {
+		dir = filepath.Dir(file)
+	}
+
+	return readBlob(dir, path)
+}
+
+// readBlob reads value stored in blob file with path relative to dir
+func readBlob(dir, path string) []byte {
+	data, err := ioutil.ReadFile(filepath.Join(dir, filepath.FromSlash(path)))
+	if err != nil {
+		panic(newErr(fmt.Errorf("reading blob: %v", err)))
+	}
+
+	if !strings.HasSuffix(path, gzipSuffix) {
+		return data
+	}
+
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		panic(newErr(fmt.Errorf("reading blob %s: %v", path, err)))
+	}
+
+	data, err = ioutil.ReadAll(r)
+	if err != nil {
+		panic(newErr(fmt.Errorf("reading blob %s: %v", path, err)))
+	}
+
+	return data
+}
@@ -0,0 +1,152 @@
+package testparrot
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testBlob []byte
+
+func TestBlobToCode(t *testing.T) {
+	generator := NewGenerator("my/go-pkg", "pkg")
+	generator.SetBlobs(t.TempDir(), 10, false)
+
+	large := strings.Repeat("a", 10)
+	blobPath := path.Join(BlobDir, "bf2cb58a68f684d95a3b78ef8f661c9a4e5b09e82cc8f9cc88cce90528caeb27")
+
+	tests := []struct {
+		value    interface{}
+		expected string
+	}{
+		{large, fmt.Sprintf("string(gotestparrot.Blob(%q))", blobPath)},
+		{[]byte(large), fmt.Sprintf("gotestparrot.Blob(%q)", blobPath)},
+		{testBlob(large), fmt.Sprintf("gotestparrot.testBlob(gotestparrot.Blob(%q))", blobPath)},
+		{"small", `"small"`},
+	}
+
+	for _, test := range tests {
+		code, err := valToCode(generator, reflect.ValueOf(test.value), reflect.Value{})
+		require.NoError(t, err)
+		require.Equal(t, test.expected, fmt.Sprintf("%#v", code))
+	}
+
+	// disabled blobs are inlined
+	generator.SetBlobs("", 0, false)
+	code, err := valToCode(generator, reflect.ValueOf(large), reflect.Value{})
+	require.NoError(t, err)
+	require.Equal(t, `"aaaaaaaaaa"`, fmt.Sprintf("%#v", code))
+}
+
+func TestGenerateBlobs(t *testing.T) {
+	for _, compress := range []bool{false, true} {
+		t.Run(fmt.Sprintf("compress %v", compress), func(t *testing.T) {
+			root := t.TempDir()
+
+			recorder := NewRecorder()
+			recorder.Load("recorder1", []Recording{{"key1", strings.Repeat("a", 100)}})
+
+			generator := NewGenerator("my/go-pkg", "pkg")
+			generator.SetBlobs(root, 50, compress)
+			opts := GenOptions{RecorderVar: "recorder"}
+			genPath := path.Join(root, "gen.go")
+
+			// blob files are not written when diffing
+			_, err := generator.Diff(recorder, opts, genPath)
+			require.NoError(t, err)
+			require.NoDirExists(t, filepath.Join(root, BlobDir))
+
+			require.NoError(t, generator.GenerateToFile(recorder, opts, genPath))
+
+			blobPaths, err := filepath.Glob(filepath.Join(root, BlobDir, "*"))
+			require.NoError(t, err)
+			require.Len(t, blobPaths, 1)
+			require.Equal(t, compress, strings.HasSuffix(blobPaths[0], gzipSuffix))
+
+			src, err := ioutil.ReadFile(genPath)
+			require.NoError(t, err)
+			require.Contains(t, string(src), path.Join(BlobDir, filepath.Base(blobPaths[0])))
+
+			// blob is read relative to directory of generated file
+			require.Equal(t, strings.Repeat("a", 100), string(readBlob(root, path.Join(BlobDir, filepath.Base(blobPaths[0])))))
+		})
+	}
+}
+
+func TestBlobMissing(t *testing.T) {
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+
+	// path is relative to directory of calling file
+	require.PanicsWithError(t,
+		"testparrot: reading blob: open "+filepath.Join(wd, "missing")+": no such file or directory",
+		func() { Blob("missing") },
+	)
+}
+
+func TestRemoveUnusedBlobs(t *testing.T) {
+	dir := t.TempDir()
+	blobDir := filepath.Join(dir, BlobDir)
+	require.NoError(t, os.MkdirAll(blobDir, 0770))
+
+	for _, name := range []string{"used", "pending", "unused"} {
+		require.NoError(t, ioutil.WriteFile(filepath.Join(blobDir, name), []byte(name), 0660))
+	}
+
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "pkg_recording_test.go"),
+		[]byte(fmt.Sprintf("var v = gotestparrot.Blob(%q)", path.Join(BlobDir, "used"))), 0660))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "pkg_recording_test.go"+PendingFileSuffix),
+		[]byte(fmt.Sprintf("var v = gotestparrot.Blob(%q)", path.Join(BlobDir, "pending"))), 0660))
+
+	require.NoError(t, removeUnusedBlobs(dir))
+	require.FileExists(t, filepath.Join(blobDir, "used"))
+	require.FileExists(t, filepath.Join(blobDir, "pending"))
+	require.NoFileExists(t, filepath.Join(blobDir, "unused"))
+
+	// package without blob directory
+	require.NoError(t, removeUnusedBlobs(t.TempDir()))
+}
+
+func TestGoStorageBlobs(t *testing.T) {
+	dir := t.TempDir()
+
+	recorder := NewRecorder()
+	recorder.Load("TestA", []Recording{{"key", strings.Repeat("a", 100)}})
+
+	generator := NewGenerator("my/go-pkg", "pkg")
+	generator.SetBlobs(dir, 50, false)
+
+	storage := NewGoStorage(recorder, generator, dir)
+	storage.RecorderVar = "recorder"
+	require.NoError(t, storage.Flush())
+
+	blobPaths, err := filepath.Glob(filepath.Join(dir, BlobDir, "*"))
+	require.NoError(t, err)
+	require.Len(t, blobPaths, 1)
+
+	// check mode reports missing blob files
+	require.NoError(t, os.Remove(blobPaths[0]))
+	storage.SetCheck(true)
+	require.NoError(t, storage.Flush())
+	require.Equal(t, []string{
+		fmt.Sprintf("blob file %s referenced by %s is missing",
+			path.Join(BlobDir, filepath.Base(blobPaths[0])), path.Join(dir, storage.Filename)),
+	}, storage.CheckDiffs())
+
+	// recordings without large values no longer reference blob files
+	storage.SetCheck(false)
+	require.NoError(t, storage.Flush())
+	require.NoError(t, storage.Save("TestA", []Recording{{"key", "small"}}))
+	require.NoError(t, storage.Flush())
+
+	blobPaths, err = filepath.Glob(filepath.Join(dir, BlobDir, "*"))
+	require.NoError(t, err)
+	require.Empty(t, blobPaths)
+}
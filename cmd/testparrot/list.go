@@ -0,0 +1,135 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"text/tabwriter"
+
+	"github.com/xtruder/go-testparrot/internal/recfile"
+	"github.com/xtruder/go-testparrot/internal/testdecl"
+)
+
+var listCommand = &command{
+	name:        "list",
+	usage:       "list [-json] [packages]",
+	description: "List tests and recording keys stored in generated recording files, with test files where tests are declared.",
+}
+
+func init() {
+	listCommand.run = runList
+}
+
+// listedTest defines recordings of a test, as listed by list command
+type listedTest struct {
+	Test string `json:"test"`
+
+	// File defines test file where test is declared, empty if test is not
+	// declared in package
+	File string `json:"file"`
+
+	// RecordingFile defines generated file where recordings are stored
+	RecordingFile string `json:"recordingFile"`
+
+	Recordings []listedRecording `json:"recordings"`
+}
+
+// listedRecording defines a single recording, as listed by list command
+type listedRecording struct {
+	Key  string `json:"key"`
+	Type string `json:"type"`
+	Size int    `json:"size"`
+}
+
+func runList(c *cli, args []string) error {
+	fs := newFlagSet(c, listCommand)
+	jsonOutput := fs.Bool("json", false, "print recordings as JSON")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	patterns := fs.Args()
+	if len(patterns) == 0 {
+		patterns = []string{"."}
+	}
+
+	dirs, err := packageDirs(patterns)
+	if err != nil {
+		return err
+	}
+
+	tests := []listedTest{}
+	for _, dir := range dirs {
+		paths, err := recfile.Find(dir)
+		if err != nil {
+			return err
+		}
+
+		if len(paths) == 0 {
+			continue
+		}
+
+		declared, err := testdecl.Find(dir)
+		if err != nil {
+			return err
+		}
+
+		for _, path := range paths {
+			file, err := recfile.ParseFile(path)
+			if err != nil {
+				return err
+			}
+
+			for _, test := range file.Tests {
+				listed := listedTest{
+					Test:          test.Name,
+					File:          declared[testdecl.TopLevel(test.Name)],
+					RecordingFile: path,
+					Recordings:    []listedRecording{},
+				}
+
+				for _, recording := range test.Recordings {
+					size, err := recording.Size(dir)
+					if err != nil {
+						return err
+					}
+
+					listed.Recordings = append(listed.Recordings, listedRecording{
+						Key:  recording.Key,
+						Type: recording.Type(),
+						Size: size,
+					})
+				}
+
+				tests = append(tests, listed)
+			}
+		}
+	}
+
+	if *jsonOutput {
+		enc := json.NewEncoder(c.out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(tests)
+	}
+
+	w := tabwriter.NewWriter(c.out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "TEST\tKEY\tTYPE\tSIZE\tFILE")
+	for _, test := range tests {
+		file := "?"
+		if test.File != "" {
+			file = filepath.Base(test.File)
+		}
+
+		for _, recording := range test.Recordings {
+			typ := recording.Type
+			if typ == "" {
+				typ = "?"
+			}
+
+			fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\n",
+				test.Test, recording.Key, typ, recording.Size, file)
+		}
+	}
+
+	return w.Flush()
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestList(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "example_recording_test.go"), recordedSrc)
+	writeFile(t, filepath.Join(dir, "blob_recording_test.go"), strings.NewReplacer(
+		`"TestA"`, `"TestB"`,
+		`"value2"`, `string(gotestparrot.Blob("testdata/testparrot/blobs/abc"))`,
+	).Replace(recordedSrc))
+	writeFile(t, filepath.Join(dir, "a_test.go"), "package example\n\nfunc TestA(t *testing.T) {}\n")
+
+	blobDir := filepath.Join(dir, "testdata", "testparrot", "blobs")
+	require.NoError(t, os.MkdirAll(blobDir, 0770))
+	writeFile(t, filepath.Join(blobDir, "abc"), strings.Repeat("a", 1000))
+
+	t.Run("plain", func(t *testing.T) {
+		c, out := newTestCli("")
+		require.Equal(t, 0, run(c, []string{"list", dir}))
+		require.Equal(t, ""+
+			"TEST   KEY  TYPE    SIZE  FILE\n"+
+			"TestB  0    string  8     ?\n"+
+			"TestB  1    string  1008  ?\n"+
+			"TestA  0    string  8     a_test.go\n"+
+			"TestA  1    string  8     a_test.go\n",
+			out.String())
+	})
+
+	t.Run("json", func(t *testing.T) {
+		c, out := newTestCli("")
+		require.Equal(t, 0, run(c, []string{"list", "-json", dir}))
+		require.JSONEq(t, `[{
+			"test": "TestB",
+			"file": "",
+			"recordingFile": "`+filepath.Join(dir, "blob_recording_test.go")+`",
+			"recordings": [
+				{"key": "0", "type": "string", "size": 8},
+				{"key": "1", "type": "string", "size": 1008}
+			]
+		}, {
+			"test": "TestA",
+			"file": "`+filepath.Join(dir, "a_test.go")+`",
+			"recordingFile": "`+filepath.Join(dir, "example_recording_test.go")+`",
+			"recordings": [
+				{"key": "0", "type": "string", "size": 8},
+				{"key": "1", "type": "string", "size": 8}
+			]
+		}]`, out.String())
+	})
+
+	t.Run("package patterns", func(t *testing.T) {
+		c, out := newTestCli("")
+		require.Equal(t, 0, run(c, []string{"list", "../../example/..."}))
+		require.Contains(t, out.String(), "TestKVExample")
+		require.Contains(t, out.String(), "file2_test.go")
+	})
+}
@@ -0,0 +1,116 @@
+// Command testparrot reviews and inspects recordings generated by testparrot.
+//
+// Usage:
+//
+//	testparrot <command> [arguments]
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+// command defines a testparrot subcommand
+type command struct {
+	// name defines name of a command
+	name string
+
+	// usage defines usage line of a command
+	usage string
+
+	// description defines short description of a command
+	description string
+
+	// run runs command with arguments
+	run func(c *cli, args []string) error
+}
+
+// commands defines all testparrot subcommands
+var commands = []*command{
+	listCommand,
+	mvCommand,
+	pruneCommand,
+	reviewCommand,
+}
+
+// errQuit is returned when user quits interactive command
+var errQuit = errors.New("quit")
+
+// cli defines input and output of a command
+type cli struct {
+	in  *bufio.Reader
+	out io.Writer
+	err io.Writer
+}
+
+// prompt writes question and reads a single line of answer
+func (c *cli) prompt(question string) (string, error) {
+	fmt.Fprint(c.out, question)
+
+	line, err := c.in.ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		return "", err
+	}
+
+	return line, nil
+}
+
+func main() {
+	c := &cli{in: bufio.NewReader(os.Stdin), out: os.Stdout, err: os.Stderr}
+	os.Exit(run(c, os.Args[1:]))
+}
+
+// run runs command with name from first argument and returns exit code
+func run(c *cli, args []string) int {
+	if len(args) == 0 {
+		usage(c.err)
+		return 2
+	}
+
+	for _, cmd := range commands {
+		if cmd.name != args[0] {
+			continue
+		}
+
+		err := cmd.run(c, args[1:])
+		switch {
+		case err == nil:
+			return 0
+		case errors.Is(err, flag.ErrHelp):
+			return 2
+		case errors.Is(err, errQuit):
+			return 1
+		default:
+			fmt.Fprintf(c.err, "testparrot %s: %v\n", cmd.name, err)
+			return 1
+		}
+	}
+
+	fmt.Fprintf(c.err, "testparrot: unknown command %q\n\n", args[0])
+	usage(c.err)
+
+	return 2
+}
+
+func usage(out io.Writer) {
+	fmt.Fprintln(out, "Usage:\n\n\ttestparrot <command> [arguments]\n\nCommands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(out, "\n\t%s\n\t\t%s\n", cmd.usage, cmd.description)
+	}
+}
+
+// newFlagSet creates flag set of a command
+func newFlagSet(c *cli, cmd *command) *flag.FlagSet {
+	fs := flag.NewFlagSet(cmd.name, flag.ContinueOnError)
+	fs.SetOutput(c.err)
+	fs.Usage = func() {
+		fmt.Fprintf(c.err, "Usage: testparrot %s\n\n%s\n", cmd.usage, cmd.description)
+		fs.PrintDefaults()
+	}
+
+	return fs
+}
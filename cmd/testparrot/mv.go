@@ -0,0 +1,224 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/xtruder/go-testparrot"
+	"github.com/xtruder/go-testparrot/internal/recfile"
+	"github.com/xtruder/go-testparrot/internal/testdecl"
+)
+
+var mvCommand = &command{
+	name:  "mv",
+	usage: "mv <pkg> <old test name> <new test name>",
+	description: "Rename recordings of a test and its subtests in generated recording files, " +
+		"moving them to another split file if test moved to another test file.",
+}
+
+func init() {
+	mvCommand.run = runMv
+}
+
+func runMv(c *cli, args []string) error {
+	fs := newFlagSet(c, mvCommand)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 3 {
+		fs.Usage()
+		return errors.New("expected package, old and new test name")
+	}
+
+	dirs, err := packageDirs(fs.Args()[:1])
+	if err != nil {
+		return err
+	}
+
+	if len(dirs) != 1 {
+		return fmt.Errorf("expected a single package, got %d", len(dirs))
+	}
+
+	return moveTest(c, dirs[0], fs.Arg(1), fs.Arg(2))
+}
+
+// renamedTest returns name of test after renaming old test to new test, and
+// whether test is renamed at all. Subtests of old test are renamed too.
+func renamedTest(name string, old string, new string) (string, bool) {
+	if name == old {
+		return new, true
+	}
+
+	if strings.HasPrefix(name, old+"/") {
+		return new + strings.TrimPrefix(name, old), true
+	}
+
+	return name, false
+}
+
+// moveTest renames recordings of old test in generated files in a directory
+func moveTest(c *cli, dir string, old string, new string) error {
+	paths, err := recfile.Find(dir)
+	if err != nil {
+		return err
+	}
+
+	files := map[string]*recfile.File{}
+	for _, path := range paths {
+		if files[path], err = recfile.ParseFile(path); err != nil {
+			return err
+		}
+	}
+
+	declared, err := testdecl.Find(dir)
+	if err != nil {
+		return err
+	}
+
+	// tests are moved to split file of test file where new test is declared,
+	// but only if recordings are split
+	var destPath string
+	if testFilename, ok := declared[testdecl.TopLevel(new)]; ok && isSplit(files, declared) {
+		destPath = filepath.Join(dir, testparrot.RecordingFilename(filepath.Base(testFilename)))
+	}
+
+	changed := map[string]bool{}
+	moved := 0
+
+	for _, path := range paths {
+		file := files[path]
+
+		kept := []*recfile.Test{}
+		for _, test := range file.Tests {
+			newName, ok := renamedTest(test.Name, old, new)
+			if !ok {
+				kept = append(kept, test)
+				continue
+			}
+
+			if err := checkNotRecorded(files, newName); err != nil {
+				return err
+			}
+
+			targetPath := path
+			if destPath != "" && destPath != path {
+				targetPath = destPath
+			}
+
+			fmt.Fprintf(c.out, "%s -> %s", test.Name, newName)
+			if targetPath != path {
+				fmt.Fprintf(c.out, " (%s -> %s)", filepath.Base(path), filepath.Base(targetPath))
+			}
+			fmt.Fprintln(c.out)
+
+			test.Name = newName
+			moved++
+
+			if targetPath == path {
+				kept = append(kept, test)
+				changed[path] = true
+				continue
+			}
+
+			target, ok := files[targetPath]
+			if !ok {
+				target = &recfile.File{Package: file.Package}
+				files[targetPath] = target
+			}
+
+			target.MergeImports(file)
+			target.Tests = append(target.Tests, test)
+			changed[path] = true
+			changed[targetPath] = true
+		}
+
+		file.Tests = kept
+	}
+
+	if moved == 0 {
+		return fmt.Errorf("no recordings found for test '%s'", old)
+	}
+
+	changedPaths := make([]string, 0, len(changed))
+	for path := range changed {
+		changedPaths = append(changedPaths, path)
+	}
+	sort.Strings(changedPaths)
+
+	for _, path := range changedPaths {
+		if err := writeRecordings(files[path], path); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// checkNotRecorded checks that test has no recordings yet
+func checkNotRecorded(files map[string]*recfile.File, name string) error {
+	for path, file := range files {
+		if file.Test(name) != nil {
+			return fmt.Errorf("recordings for test '%s' already exist in %s", name, path)
+		}
+	}
+
+	return nil
+}
+
+// isSplit checks whether recordings of a package are split into generated
+// files of test files. Generated file of a package without split files holds
+// tests of all test files and is named after package, so it can have the
+// same name as split file of a test file, like foo_recording_test.go of
+// package foo with foo_test.go.
+func isSplit(files map[string]*recfile.File, declared map[string]string) bool {
+	for path, file := range files {
+		for _, test := range file.Tests {
+			testFilename, ok := declared[testdecl.TopLevel(test.Name)]
+			if ok && testparrot.RecordingFilename(filepath.Base(testFilename)) != filepath.Base(path) {
+				return false
+			}
+		}
+	}
+
+	if len(files) != 1 {
+		return len(files) > 1
+	}
+
+	for path, file := range files {
+		return filepath.Base(path) != testparrot.RecordingFilename(file.Package+"_test.go")
+	}
+
+	return false
+}
+
+// writeRecordings writes generated file, or removes it if it has no recordings
+func writeRecordings(file *recfile.File, path string) error {
+	empty := true
+	for _, test := range file.Tests {
+		if len(test.Recordings) > 0 {
+			empty = false
+		}
+	}
+
+	if empty {
+		err := os.Remove(path)
+		if os.IsNotExist(err) {
+			return nil
+		}
+
+		return err
+	}
+
+	src, err := file.Format()
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, src, 0660)
+}
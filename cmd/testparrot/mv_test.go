@@ -0,0 +1,115 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMv(t *testing.T) {
+	t.Run("rename in single file", func(t *testing.T) {
+		dir := t.TempDir()
+		genPath := filepath.Join(dir, "example_recording_test.go")
+		writeFile(t, genPath, recordedSrc)
+		writeFile(t, filepath.Join(dir, "a_test.go"), "package example\n\nfunc TestB(t *testing.T) {}\n")
+
+		c, out := newTestCli("")
+		require.Equal(t, 0, run(c, []string{"mv", dir, "TestA", "TestB"}))
+		require.Equal(t, "TestA -> TestB\n", out.String())
+
+		src, err := ioutil.ReadFile(genPath)
+		require.NoError(t, err)
+		require.Equal(t, strings.Replace(recordedSrc, `"TestA"`, `"TestB"`, 1), string(src))
+	})
+
+	t.Run("rename subtests", func(t *testing.T) {
+		dir := t.TempDir()
+		genPath := filepath.Join(dir, "example_recording_test.go")
+		writeFile(t, genPath, strings.Replace(recordedSrc, `"TestA"`, `"TestA/sub"`, 1))
+
+		c, out := newTestCli("")
+		require.Equal(t, 0, run(c, []string{"mv", dir, "TestA", "TestB"}))
+		require.Equal(t, "TestA/sub -> TestB/sub\n", out.String())
+
+		src, err := ioutil.ReadFile(genPath)
+		require.NoError(t, err)
+		require.Equal(t, strings.Replace(recordedSrc, `"TestA"`, `"TestB/sub"`, 1), string(src))
+	})
+
+	t.Run("move between split files", func(t *testing.T) {
+		dir := t.TempDir()
+		oldPath := filepath.Join(dir, "a_recording_test.go")
+		newPath := filepath.Join(dir, "b_recording_test.go")
+		writeFile(t, oldPath, recordedSrc)
+		writeFile(t, filepath.Join(dir, "a_test.go"), "package example\n")
+		writeFile(t, filepath.Join(dir, "b_test.go"), "package example\n\nfunc TestB(t *testing.T) {}\n")
+
+		c, out := newTestCli("")
+		require.Equal(t, 0, run(c, []string{"mv", dir, "TestA", "TestB"}))
+		require.Equal(t, "TestA -> TestB (a_recording_test.go -> b_recording_test.go)\n", out.String())
+
+		require.NoFileExists(t, oldPath)
+
+		src, err := ioutil.ReadFile(newPath)
+		require.NoError(t, err)
+		require.Equal(t, strings.Replace(recordedSrc, `"TestA"`, `"TestB"`, 1), string(src))
+	})
+
+	t.Run("rename without split files", func(t *testing.T) {
+		dir := t.TempDir()
+		genPath := filepath.Join(dir, "example_recording_test.go")
+		writeFile(t, genPath, recordedSrc)
+		writeFile(t, filepath.Join(dir, "example_test.go"), "package example\n\nfunc TestA(t *testing.T) {}\n")
+		writeFile(t, filepath.Join(dir, "b_test.go"), "package example\n\nfunc TestB(t *testing.T) {}\n")
+
+		c, out := newTestCli("")
+		require.Equal(t, 0, run(c, []string{"mv", dir, "TestA", "TestB"}))
+		require.Equal(t, "TestA -> TestB\n", out.String())
+
+		require.NoFileExists(t, filepath.Join(dir, "b_recording_test.go"))
+
+		src, err := ioutil.ReadFile(genPath)
+		require.NoError(t, err)
+		require.Equal(t, strings.Replace(recordedSrc, `"TestA"`, `"TestB"`, 1), string(src))
+	})
+
+	t.Run("no recordings", func(t *testing.T) {
+		dir := t.TempDir()
+		writeFile(t, filepath.Join(dir, "example_recording_test.go"), recordedSrc)
+
+		c, out := newTestCli("")
+		require.Equal(t, 1, run(c, []string{"mv", dir, "TestC", "TestB"}))
+		require.Equal(t, "testparrot mv: no recordings found for test 'TestC'\n", out.String())
+	})
+
+	t.Run("recordings already exist", func(t *testing.T) {
+		dir := t.TempDir()
+		writeFile(t, filepath.Join(dir, "example_recording_test.go"), recordedSrc)
+
+		c, out := newTestCli("")
+		require.Equal(t, 1, run(c, []string{"mv", dir, "TestA", "TestA"}))
+		require.Contains(t, out.String(), "recordings for test 'TestA' already exist")
+	})
+}
+
+func TestRenamedTest(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected string
+		renamed  bool
+	}{
+		{"TestA", "TestB", true},
+		{"TestA/sub", "TestB/sub", true},
+		{"TestAB", "TestAB", false},
+		{"TestC", "TestC", false},
+	}
+
+	for _, test := range tests {
+		name, renamed := renamedTest(test.name, "TestA", "TestB")
+		require.Equal(t, test.expected, name)
+		require.Equal(t, test.renamed, renamed)
+	}
+}
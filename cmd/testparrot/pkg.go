@@ -0,0 +1,34 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// packageDirs resolves package patterns to package directories. Existing
+// directories are used as they are, other patterns are resolved using go list.
+func packageDirs(patterns []string) ([]string, error) {
+	dirs := []string{}
+	for _, pattern := range patterns {
+		if info, err := os.Stat(pattern); err == nil && info.IsDir() {
+			dirs = append(dirs, pattern)
+			continue
+		}
+
+		stderr := &bytes.Buffer{}
+		cmd := exec.Command("go", "list", "-f", "{{.Dir}}", pattern)
+		cmd.Stderr = stderr
+
+		out, err := cmd.Output()
+		if err != nil {
+			return nil, fmt.Errorf("go list %s: %v: %s", pattern, err, strings.TrimSpace(stderr.String()))
+		}
+
+		dirs = append(dirs, strings.Fields(string(out))...)
+	}
+
+	return dirs, nil
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/xtruder/go-testparrot"
+	"github.com/xtruder/go-testparrot/internal/recfile"
+)
+
+var pruneCommand = &command{
+	name:  "prune",
+	usage: "prune [-dryrun] [packages]",
+	description: "Run tests of packages and drop recordings of tests that did not run " +
+		"and recordings that were not used.",
+}
+
+func init() {
+	pruneCommand.run = runPrune
+}
+
+func runPrune(c *cli, args []string) error {
+	fs := newFlagSet(c, pruneCommand)
+	dryRun := fs.Bool("dryrun", false, "only print recordings that would be dropped")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	patterns := fs.Args()
+	if len(patterns) == 0 {
+		patterns = []string{"./..."}
+	}
+
+	dirs, err := packageDirs(patterns)
+	if err != nil {
+		return err
+	}
+
+	for _, dir := range dirs {
+		if err := prunePackage(c, dir, *dryRun); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// prunePackage runs tests of a package and drops recordings that were not
+// used from its generated files
+func prunePackage(c *cli, dir string, dryRun bool) error {
+	paths, err := recfile.Find(dir)
+	if err != nil {
+		return err
+	}
+
+	// packages without recordings are not tested
+	if len(paths) == 0 {
+		return nil
+	}
+
+	usage, err := testUsage(dir)
+	if err != nil {
+		return err
+	}
+
+	for _, path := range paths {
+		file, err := recfile.ParseFile(path)
+		if err != nil {
+			return err
+		}
+
+		if !pruneFile(c, path, file, usage) || dryRun {
+			continue
+		}
+
+		if err := writeRecordings(file, path); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// testUsage runs tests of a package in replay mode and returns usage of
+// recordings by test name. Benchmarks run once, so their recordings are used
+// too.
+func testUsage(dir string) (map[string]testparrot.TestUsage, error) {
+	usageFile, err := ioutil.TempFile("", "testparrot-usage-*.json")
+	if err != nil {
+		return nil, err
+	}
+	usageFile.Close()
+	defer os.Remove(usageFile.Name())
+
+	output := &bytes.Buffer{}
+	cmd := exec.Command("go", "test", "-count=1", "-bench=.", "-benchtime=1x", ".",
+		"-testparrot.usagefile="+usageFile.Name())
+	cmd.Dir = dir
+	cmd.Stdout = output
+	cmd.Stderr = output
+
+	// usage is incomplete if tests fail, so nothing is pruned
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("tests in %s failed: %v\n%s", dir, err, output.String())
+	}
+
+	data, err := ioutil.ReadFile(usageFile.Name())
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) == 0 {
+		return nil, errors.New("tests in " + dir + " did not write recordings usage, do they run testparrot.Run in TestMain?")
+	}
+
+	tests := []testparrot.TestUsage{}
+	if err := json.Unmarshal(data, &tests); err != nil {
+		return nil, err
+	}
+
+	usage := map[string]testparrot.TestUsage{}
+	for _, test := range tests {
+		usage[test.Test] = test
+	}
+
+	return usage, nil
+}
+
+// pruneFile drops recordings that were not used from generated file and
+// reports whether file changed
+func pruneFile(c *cli, path string, file *recfile.File, usage map[string]testparrot.TestUsage) bool {
+	changed := false
+	filename := filepath.Base(path)
+
+	for _, test := range file.Tests {
+		testUsage, ok := usage[test.Name]
+		if !ok || !testUsage.Used {
+			fmt.Fprintf(c.out, "%s: dropped recordings of test '%s' that did not run\n", filename, test.Name)
+
+			test.Recordings = nil
+			changed = true
+
+			continue
+		}
+
+		unused := map[int]bool{}
+		for _, i := range testUsage.Unused {
+			unused[i] = true
+		}
+
+		kept := []recfile.Recording{}
+		for i, recording := range test.Recordings {
+			if !unused[i] {
+				kept = append(kept, recording)
+				continue
+			}
+
+			fmt.Fprintf(c.out, "%s: dropped unused recording with key %s of test '%s'\n",
+				filename, recording.Key, test.Name)
+			changed = true
+		}
+
+		test.Recordings = kept
+	}
+
+	return changed
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/xtruder/go-testparrot"
+	"github.com/xtruder/go-testparrot/internal/recfile"
+)
+
+func TestPruneFile(t *testing.T) {
+	newFile := func() *recfile.File {
+		return &recfile.File{Tests: []*recfile.Test{
+			{Name: "TestA", Recordings: []recfile.Recording{{Key: "0", Value: "1"}, {Key: "1", Value: "2"}}},
+			{Name: "TestB", Recordings: []recfile.Recording{{Key: "0", Value: "1"}}},
+			{Name: "TestC", Recordings: []recfile.Recording{{Key: "0", Value: "1"}}},
+		}}
+	}
+
+	t.Run("drop unused", func(t *testing.T) {
+		file := newFile()
+
+		c, out := newTestCli("")
+		require.True(t, pruneFile(c, "dir/example_recording_test.go", file, map[string]testparrot.TestUsage{
+			"TestA": {Test: "TestA", Used: true, Unused: []int{0}},
+			"TestB": {Test: "TestB", Used: false, Unused: []int{0}},
+		}))
+
+		require.Equal(t, []*recfile.Test{
+			{Name: "TestA", Recordings: []recfile.Recording{{Key: "1", Value: "2"}}},
+			{Name: "TestB"},
+			{Name: "TestC"},
+		}, file.Tests)
+		require.Equal(t, ""+
+			"example_recording_test.go: dropped unused recording with key 0 of test 'TestA'\n"+
+			"example_recording_test.go: dropped recordings of test 'TestB' that did not run\n"+
+			"example_recording_test.go: dropped recordings of test 'TestC' that did not run\n",
+			out.String())
+	})
+
+	t.Run("all used", func(t *testing.T) {
+		file := newFile()
+
+		c, out := newTestCli("")
+		require.False(t, pruneFile(c, "example_recording_test.go", file, map[string]testparrot.TestUsage{
+			"TestA": {Test: "TestA", Used: true},
+			"TestB": {Test: "TestB", Used: true},
+			"TestC": {Test: "TestC", Used: true},
+		}))
+		require.Equal(t, newFile(), file)
+		require.Empty(t, out.String())
+	})
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pmezard/go-difflib/difflib"
+	"github.com/xtruder/go-testparrot"
+	"github.com/xtruder/go-testparrot/internal/recfile"
+)
+
+var reviewCommand = &command{
+	name:  "review",
+	usage: "review [-accept] [dir...]",
+	description: "Review pending recordings written by tests run with -testparrot.review " +
+		"and generate recording files from accepted changes.",
+}
+
+func init() {
+	reviewCommand.run = runReview
+}
+
+func runReview(c *cli, args []string) error {
+	fs := newFlagSet(c, reviewCommand)
+	acceptAll := fs.Bool("accept", false, "accept all pending changes without prompting")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	dirs := fs.Args()
+	if len(dirs) == 0 {
+		dirs = []string{"."}
+	}
+
+	pendingPaths := []string{}
+	for _, dir := range dirs {
+		paths, err := filepath.Glob(filepath.Join(dir, "*"+testparrot.PendingFileSuffix))
+		if err != nil {
+			return err
+		}
+
+		pendingPaths = append(pendingPaths, paths...)
+	}
+	sort.Strings(pendingPaths)
+
+	if len(pendingPaths) == 0 {
+		fmt.Fprintln(c.out, "no pending recordings")
+		return nil
+	}
+
+	for _, pendingPath := range pendingPaths {
+		if err := reviewFile(c, pendingPath, acceptAll); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// reviewFile reviews changes of a single pending file and writes generated
+// file with accepted changes
+func reviewFile(c *cli, pendingPath string, acceptAll *bool) error {
+	genPath := strings.TrimSuffix(pendingPath, testparrot.PendingFileSuffix)
+
+	pending, err := recfile.ParseFile(pendingPath)
+	if err != nil {
+		return err
+	}
+
+	var existing *recfile.File
+	if _, err := os.Stat(genPath); err == nil {
+		if existing, err = recfile.ParseFile(genPath); err != nil {
+			return err
+		}
+	}
+
+	changes := recfile.Changes(existing, pending)
+	rejected := []recfile.Change{}
+
+	for i, change := range changes {
+		if *acceptAll {
+			continue
+		}
+
+		fmt.Fprintf(c.out, "\n%s (%d/%d): %s recording with key %s\n%s",
+			genPath, i+1, len(changes), change.Kind, change.Key, formatChange(change))
+
+		accepted, err := promptAccept(c, acceptAll)
+		if err != nil {
+			return err
+		}
+
+		if !accepted {
+			rejected = append(rejected, change)
+		}
+	}
+
+	src, err := recfile.Revert(existing, pending, rejected).Format()
+	if err != nil {
+		return err
+	}
+
+	if err := ioutil.WriteFile(genPath, src, 0660); err != nil {
+		return err
+	}
+
+	if err := os.Remove(pendingPath); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(c.out, "%s: accepted %d, rejected %d changes\n",
+		genPath, len(changes)-len(rejected), len(rejected))
+
+	return nil
+}
+
+// promptAccept asks whether to accept a change, answering all accepts all
+// remaining changes
+func promptAccept(c *cli, acceptAll *bool) (bool, error) {
+	for {
+		answer, err := c.prompt("accept? [y]es, [n]o, [a]ll, [q]uit: ")
+		if err != nil {
+			return false, errQuit
+		}
+
+		switch strings.ToLower(strings.TrimSpace(answer)) {
+		case "y", "yes":
+			return true, nil
+		case "n", "no":
+			return false, nil
+		case "a", "all":
+			*acceptAll = true
+			return true, nil
+		case "q", "quit":
+			return false, errQuit
+		}
+	}
+}
+
+// formatChange formats change as unified diff of recorded and new value
+func formatChange(change recfile.Change) string {
+	diff, _ := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        splitValue(change.Old),
+		B:        splitValue(change.New),
+		FromFile: change.Test + " (recorded)",
+		ToFile:   change.Test + " (new)",
+		Context:  3,
+	})
+
+	return diff
+}
+
+func splitValue(value string) []string {
+	if value == "" {
+		return nil
+	}
+
+	return difflib.SplitLines(value)
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const recordedSrc = `// Code generated by testparrot. DO NOT EDIT.
+
+package example
+
+import gotestparrot "github.com/xtruder/go-testparrot"
+
+func init() {
+	gotestparrot.R.Load("TestA", []gotestparrot.Recording{{
+		Key:   0,
+		Value: "value1",
+	}, {
+		Key:   1,
+		Value: "value2",
+	}})
+}
+`
+
+const pendingSrc = `// Code generated by testparrot. DO NOT EDIT.
+
+package example
+
+import gotestparrot "github.com/xtruder/go-testparrot"
+
+func init() {
+	gotestparrot.R.Load("TestA", []gotestparrot.Recording{{
+		Key:   0,
+		Value: "value3",
+	}, {
+		Key:   1,
+		Value: "value4",
+	}})
+}
+`
+
+// newTestCli creates cli with input and captured output
+func newTestCli(input string) (*cli, *bytes.Buffer) {
+	out := &bytes.Buffer{}
+	return &cli{in: bufio.NewReader(strings.NewReader(input)), out: out, err: out}, out
+}
+
+// writeFile writes file in test directory
+func writeFile(t *testing.T, path string, contents string) {
+	require.NoError(t, ioutil.WriteFile(path, []byte(contents), 0660))
+}
+
+func TestReview(t *testing.T) {
+	t.Run("accept and reject", func(t *testing.T) {
+		dir := t.TempDir()
+		genPath := filepath.Join(dir, "example_recording_test.go")
+		writeFile(t, genPath, recordedSrc)
+		writeFile(t, genPath+".pending", pendingSrc)
+
+		c, out := newTestCli("y\nn\n")
+		require.Equal(t, 0, run(c, []string{"review", dir}))
+		require.Contains(t, out.String(), "changed recording with key 0")
+		require.Contains(t, out.String(), "-\"value1\"\n+\"value3\"\n")
+		require.Contains(t, out.String(), "accepted 1, rejected 1 changes")
+
+		contents, err := ioutil.ReadFile(genPath)
+		require.NoError(t, err)
+		require.Equal(t, strings.Replace(recordedSrc, "value1", "value3", 1), string(contents))
+		require.NoFileExists(t, genPath+".pending")
+	})
+
+	t.Run("accept all", func(t *testing.T) {
+		dir := t.TempDir()
+		genPath := filepath.Join(dir, "example_recording_test.go")
+		writeFile(t, genPath+".pending", pendingSrc)
+
+		c, _ := newTestCli("")
+		require.Equal(t, 0, run(c, []string{"review", "-accept", dir}))
+
+		contents, err := ioutil.ReadFile(genPath)
+		require.NoError(t, err)
+		require.Equal(t, pendingSrc, string(contents))
+	})
+
+	t.Run("quit", func(t *testing.T) {
+		dir := t.TempDir()
+		genPath := filepath.Join(dir, "example_recording_test.go")
+		writeFile(t, genPath, recordedSrc)
+		writeFile(t, genPath+".pending", pendingSrc)
+
+		c, _ := newTestCli("q\n")
+		require.Equal(t, 1, run(c, []string{"review", dir}))
+
+		// files are left untouched
+		contents, err := ioutil.ReadFile(genPath)
+		require.NoError(t, err)
+		require.Equal(t, recordedSrc, string(contents))
+		require.FileExists(t, genPath+".pending")
+	})
+}
+
+func TestRunUnknownCommand(t *testing.T) {
+	c, out := newTestCli("")
+	require.Equal(t, 2, run(c, []string{"unknown"}))
+	require.Contains(t, out.String(), "unknown command \"unknown\"")
+}
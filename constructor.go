@@ -0,0 +1,143 @@
+package testparrot
+
+import (
+	"fmt"
+	"reflect"
+	"runtime"
+	"strings"
+
+	. "github.com/dave/jennifer/jen"
+)
+
+// constructor defines a registered constructor of values of a type with
+// unexported fields
+type constructor struct {
+	// pkgPath defines path of package with constructor function
+	pkgPath string
+
+	// name defines name of constructor function
+	name string
+
+	// fn defines constructor function
+	fn reflect.Value
+
+	// fnType defines type of constructor function
+	fnType reflect.Type
+
+	// args defines function returning arguments of constructor for a value
+	args reflect.Value
+}
+
+// RegisterConstructor method registers constructor of values of a type with
+// unexported fields, which cannot be generated as struct literals.
+// Constructor must be a top level function returning a value of the type,
+// like money.New, and args must be a function that takes a value of the
+// type and returns arguments of constructor, that create an equal value:
+//
+//	R.RegisterConstructor(money.New, func(m money.Money) []interface{} {
+//		return []interface{}{m.Amount(), m.Currency()}
+//	})
+//
+// Generated code creates values of the type by calling constructor.
+func (r *Recorder) RegisterConstructor(fn interface{}, args interface{}) {
+	fnVal := reflect.ValueOf(fn)
+	if fnVal.Kind() != reflect.Func || fnVal.Type().NumOut() != 1 {
+		panic(newErr(fmt.Errorf("constructor must be a function returning a single value, got '%T'", fn)))
+	}
+
+	typ := fnVal.Type().Out(0)
+
+	argsVal := reflect.ValueOf(args)
+	if argsVal.Kind() != reflect.Func || argsVal.Type().NumIn() != 1 || argsVal.Type().In(0) != typ ||
+		argsVal.Type().NumOut() != 1 || argsVal.Type().Out(0) != reflect.TypeOf([]interface{}{}) {
+		panic(newErr(fmt.Errorf("constructor args must be a function 'func(%v) []interface{}', got '%T'", typ, args)))
+	}
+
+	// only top level functions can be referenced in generated code, names
+	// of closures and methods contain dots after package path
+	fullName := runtime.FuncForPC(fnVal.Pointer()).Name()
+	pkgEnd := strings.LastIndex(fullName, "/") + 1
+	pkgEnd += strings.Index(fullName[pkgEnd:], ".")
+
+	name := fullName[pkgEnd+1:]
+	if strings.ContainsAny(name, ".()[]") {
+		panic(newErr(fmt.Errorf("constructor must be a top level function, got '%s'", fullName)))
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.constructors == nil {
+		r.constructors = map[reflect.Type]constructor{}
+	}
+
+	r.constructors[typ] = constructor{pkgPath: fullName[:pkgEnd], name: name, fn: fnVal, fnType: fnVal.Type(), args: argsVal}
+}
+
+// getConstructors returns a copy of registered constructors
+func (r *Recorder) getConstructors() map[reflect.Type]constructor {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	result := make(map[reflect.Type]constructor, len(r.constructors))
+	for typ, c := range r.constructors {
+		result[typ] = c
+	}
+
+	return result
+}
+
+// constructorToCode generates call of registered constructor of value type,
+// nil code is returned if type has no registered constructor
+func constructorToCode(g *Generator, value reflect.Value) (Code, error) {
+	c, ok := g.constructors[value.Type()]
+	if !ok {
+		return nil, nil
+	}
+
+	args, err := c.callArgs(value)
+	if err != nil {
+		return nil, err
+	}
+
+	argsCode := make([]Code, 0, len(args))
+	for _, arg := range args {
+		code, err := valToCode(g, reflect.ValueOf(arg), reflect.Value{})
+		if err != nil {
+			return nil, err
+		}
+
+		argsCode = append(argsCode, code)
+	}
+
+	var fn *Statement
+	if c.pkgPath == g.pkgPath {
+		fn = Id(c.name)
+	} else {
+		fn = Qual(c.pkgPath, c.name)
+	}
+
+	return fn.Call(argsCode...), nil
+}
+
+// callArgs returns arguments of constructor, which create value
+func (c constructor) callArgs(value reflect.Value) ([]interface{}, error) {
+	args := c.args.Call([]reflect.Value{value})[0].Interface().([]interface{})
+
+	numIn := c.fnType.NumIn()
+	if len(args) != numIn && !(c.fnType.IsVariadic() && len(args) >= numIn-1) {
+		return nil, fmt.Errorf("constructor %s.%s of type '%v' takes %d arguments, got %d",
+			c.pkgPath, c.name, value.Type(), numIn, len(args))
+	}
+
+	return args, nil
+}
+
+// argType returns type of i-th argument of constructor
+func (c constructor) argType(i int) reflect.Type {
+	if c.fnType.IsVariadic() && i >= c.fnType.NumIn()-1 {
+		return c.fnType.In(c.fnType.NumIn() - 1).Elem()
+	}
+
+	return c.fnType.In(i)
+}
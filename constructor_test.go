@@ -0,0 +1,110 @@
+package testparrot
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testMoney struct {
+	amount   int64
+	currency string
+}
+
+func newTestMoney(amount int64, currency string) testMoney {
+	return testMoney{amount, currency}
+}
+
+func testMoneyArgs(m testMoney) []interface{} {
+	return []interface{}{m.amount, m.currency}
+}
+
+// testCounter has unexported state, which is serialized with gob
+type testCounter struct {
+	count int
+}
+
+func (c testCounter) GobEncode() ([]byte, error) {
+	return []byte(fmt.Sprint(c.count)), nil
+}
+
+func (c *testCounter) GobDecode(data []byte) error {
+	_, err := fmt.Sscan(string(data), &c.count)
+	return err
+}
+
+func TestRecorderRegisterConstructor(t *testing.T) {
+	t.Run("register constructor", func(t *testing.T) {
+		recorder := NewRecorder()
+		recorder.RegisterConstructor(newTestMoney, testMoneyArgs)
+
+		c := recorder.getConstructors()[reflect.TypeOf(testMoney{})]
+		require.Equal(t, pkgPath, c.pkgPath)
+		require.Equal(t, "newTestMoney", c.name)
+	})
+
+	t.Run("closure constructor", func(t *testing.T) {
+		recorder := NewRecorder()
+		require.Panics(t, func() {
+			recorder.RegisterConstructor(func() testMoney { return testMoney{} }, testMoneyArgs)
+		})
+	})
+
+	t.Run("invalid args", func(t *testing.T) {
+		recorder := NewRecorder()
+		require.PanicsWithError(t,
+			"testparrot: constructor args must be a function 'func(testparrot.testMoney) []interface{}', got 'func(testparrot.testMoney) []string'",
+			func() {
+				recorder.RegisterConstructor(newTestMoney, func(testMoney) []string { return nil })
+			})
+	})
+}
+
+func TestUnexportedFieldsToCode(t *testing.T) {
+	type wallet struct {
+		Owner string
+		Money testMoney
+	}
+
+	t.Run("constructor", func(t *testing.T) {
+		recorder := NewRecorder()
+		recorder.RegisterConstructor(newTestMoney, testMoneyArgs)
+
+		g := NewGenerator(pkgPath, pkgName)
+		g.constructors = recorder.getConstructors()
+
+		code, err := valToCode(g, reflect.ValueOf(wallet{Owner: "owner", Money: newTestMoney(100, "EUR")}), reflect.Value{})
+		require.NoError(t, err)
+		require.Equal(t, "wallet{\n\tMoney: newTestMoney(int64(100), \"EUR\"),\n\tOwner: \"owner\",\n}", fmt.Sprintf("%#v", code))
+	})
+
+	t.Run("serialized", func(t *testing.T) {
+		g := NewGenerator(pkgPath, pkgName)
+
+		code, err := valToCode(g, reflect.ValueOf(testCounter{count: 5}), reflect.Value{})
+		require.NoError(t, err)
+		require.Equal(t, "gotestparrot.Decode([]uint8{uint8(0x35)}, testCounter{}).(testCounter)", fmt.Sprintf("%#v", code))
+
+		require.Equal(t, testCounter{count: 5}, Decode([]byte("5"), testCounter{}))
+	})
+
+	t.Run("zero unexported fields", func(t *testing.T) {
+		g := NewGenerator(pkgPath, pkgName)
+
+		code, err := valToCode(g, reflect.ValueOf(wallet{Owner: "owner"}), reflect.Value{})
+		require.NoError(t, err)
+		require.Equal(t, "wallet{Owner: \"owner\"}", fmt.Sprintf("%#v", code))
+	})
+
+	t.Run("lost unexported fields", func(t *testing.T) {
+		g := NewGenerator(pkgPath, pkgName)
+
+		_, err := valToCode(g, reflect.ValueOf(wallet{Money: testMoney{100, "EUR"}}), reflect.Value{})
+		require.Error(t, err)
+		require.True(t, strings.HasPrefix(err.Error(),
+			"cannot record unexported fields amount, currency of type 'testparrot.testMoney'"), err.Error())
+	})
+}
@@ -0,0 +1,448 @@
+package testparrot
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Difference describes a single difference between recorded and actual value
+type Difference struct {
+	// Path defines path of a value that differs, like .Dogs[1].Breed
+	Path string
+
+	// Recorded defines formatted recorded value
+	Recorded string
+
+	// Actual defines formatted actual value
+	Actual string
+}
+
+func (d Difference) String() string {
+	path := d.Path
+	if path == "" {
+		path = "."
+	}
+
+	return fmt.Sprintf("%s: recorded %s, actual %s", path, d.Recorded, d.Actual)
+}
+
+// CompareOption configures comparison of recorded and actual values
+type CompareOption func(opts *compareOptions)
+
+// compareOptions defines options of comparison. Options apply to values
+// with field paths, like .Auth.Token, where elements of slices, arrays and
+// maps are not part of the path, or to all values if no paths are given.
+type compareOptions struct {
+	// ignored defines paths of ignored fields
+	ignored map[string]bool
+
+	// tolerance defines tolerance of all floats
+	tolerance float64
+
+	// tolerances defines tolerances of floats by paths
+	tolerances map[string]float64
+
+	// unordered defines whether all slices are compared as unordered sets
+	unordered bool
+
+	// unorderedPaths defines paths of slices compared as unordered sets
+	unorderedPaths map[string]bool
+
+	// extraKeys defines whether extra keys of all actual maps are ignored
+	extraKeys bool
+
+	// extraKeysPaths defines paths of maps whose extra keys are ignored
+	extraKeysPaths map[string]bool
+}
+
+func newCompareOptions(opts []CompareOption) *compareOptions {
+	result := &compareOptions{
+		ignored:        map[string]bool{},
+		tolerances:     map[string]float64{},
+		unorderedPaths: map[string]bool{},
+		extraKeysPaths: map[string]bool{},
+	}
+
+	for _, opt := range opts {
+		opt(result)
+	}
+
+	return result
+}
+
+// IgnoreFields option ignores fields with paths when comparing values
+func IgnoreFields(paths ...string) CompareOption {
+	return func(opts *compareOptions) {
+		for _, path := range paths {
+			opts.ignored[fieldPath(path)] = true
+		}
+	}
+}
+
+// FloatTolerance option compares floats with paths, or all floats if no
+// paths are given, within absolute tolerance
+func FloatTolerance(tolerance float64, paths ...string) CompareOption {
+	return func(opts *compareOptions) {
+		if len(paths) == 0 {
+			opts.tolerance = tolerance
+		}
+
+		for _, path := range paths {
+			opts.tolerances[fieldPath(path)] = tolerance
+		}
+	}
+}
+
+// UnorderedSlices option compares slices and arrays with paths, or all
+// slices and arrays if no paths are given, as unordered sets
+func UnorderedSlices(paths ...string) CompareOption {
+	return func(opts *compareOptions) {
+		if len(paths) == 0 {
+			opts.unordered = true
+		}
+
+		for _, path := range paths {
+			opts.unorderedPaths[fieldPath(path)] = true
+		}
+	}
+}
+
+// IgnoreExtraKeys option ignores keys of actual maps with paths, or of all
+// maps if no paths are given, which are not in recorded maps
+func IgnoreExtraKeys(paths ...string) CompareOption {
+	return func(opts *compareOptions) {
+		if len(paths) == 0 {
+			opts.extraKeys = true
+		}
+
+		for _, path := range paths {
+			opts.extraKeysPaths[fieldPath(path)] = true
+		}
+	}
+}
+
+// fieldPath returns field path with leading dot
+func fieldPath(path string) string {
+	if !strings.HasPrefix(path, ".") {
+		return "." + path
+	}
+
+	return path
+}
+
+// fieldOptions defines comparison options of value of a single field
+type fieldOptions struct {
+	tolerance float64
+	unordered bool
+	extraKeys bool
+}
+
+// forField returns comparison options of field with path. Options set by
+// path take precedence over options of struct tag, which take precedence
+// over options of all values.
+func (o *compareOptions) forField(path string, tag tagOptions) fieldOptions {
+	result := fieldOptions{
+		tolerance: o.tolerance,
+		unordered: o.unordered || tag.unordered || o.unorderedPaths[path],
+		extraKeys: o.extraKeys || tag.extraKeys || o.extraKeysPaths[path],
+	}
+
+	if tag.hasTolerance {
+		result.tolerance = tag.tolerance
+	}
+
+	if tolerance, ok := o.tolerances[path]; ok {
+		result.tolerance = tolerance
+	}
+
+	return result
+}
+
+// Diff compares recorded and actual value field by field and returns all
+// differences between them. Comparison is configured with options and with
+// testparrot struct tags of fields: `testparrot:"ignore"` and fields that are
+// never recorded with `testparrot:"-"` are ignored,
+// `testparrot:"approx=0.01"` compares floats within tolerance,
+// `testparrot:"unordered"` compares slices as unordered sets and
+// `testparrot:"extrakeys"` ignores extra keys of actual maps.
+//
+// Diff returns an error if a struct tag of compared values is invalid.
+func Diff(recorded interface{}, actual interface{}, opts ...CompareOption) ([]Difference, error) {
+	d := &differ{visited: map[visit]bool{}, opts: newCompareOptions(opts)}
+	d.diff("", "", d.opts.forField("", tagOptions{}), reflect.ValueOf(recorded), reflect.ValueOf(actual))
+
+	if d.err != nil {
+		return nil, newErr(d.err)
+	}
+
+	return d.diffs, nil
+}
+
+// formatDiff formats differences as a multiline string
+func formatDiff(diffs []Difference) string {
+	lines := make([]string, 0, len(diffs))
+	for _, diff := range diffs {
+		lines = append(lines, "\t"+diff.String())
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// visit defines a pair of compared pointers, used to detect cycles
+type visit struct {
+	recorded uintptr
+	actual   uintptr
+	typ      reflect.Type
+}
+
+type differ struct {
+	diffs   []Difference
+	visited map[visit]bool
+	opts    *compareOptions
+
+	// err defines first invalid struct tag error, comparison stops on it
+	err error
+}
+
+func (d *differ) report(path string, recorded reflect.Value, actual reflect.Value) {
+	d.diffs = append(d.diffs, Difference{
+		Path:     path,
+		Recorded: formatValue(recorded),
+		Actual:   formatValue(actual),
+	})
+}
+
+// equal returns whether values are equal, without reporting differences
+func (d *differ) equal(fieldPath string, fo fieldOptions, recorded reflect.Value, actual reflect.Value) bool {
+	visited := make(map[visit]bool, len(d.visited))
+	for v := range d.visited {
+		visited[v] = true
+	}
+
+	sub := &differ{visited: visited, opts: d.opts}
+	sub.diff("", fieldPath, fo, recorded, actual)
+
+	if sub.err != nil {
+		d.err = sub.err
+	}
+
+	return len(sub.diffs) == 0
+}
+
+// diff compares recorded and actual value with path, like .Dogs[1].Breed,
+// and field path, like .Dogs.Breed, by which comparison options are selected
+func (d *differ) diff(path string, fieldPath string, fo fieldOptions, recorded reflect.Value, actual reflect.Value) {
+	if d.err != nil {
+		return
+	}
+
+	if !recorded.IsValid() || !actual.IsValid() {
+		if recorded.IsValid() != actual.IsValid() {
+			d.report(path, recorded, actual)
+		}
+
+		return
+	}
+
+	if recorded.Type() != actual.Type() {
+		d.report(path, recorded, actual)
+		return
+	}
+
+	// types like time.Time define their own equality
+	if equal, ok := equalMethod(recorded, actual); ok {
+		if !equal {
+			d.report(path, recorded, actual)
+		}
+
+		return
+	}
+
+	switch recorded.Kind() {
+	case reflect.Ptr:
+		if recorded.IsNil() || actual.IsNil() {
+			if recorded.IsNil() != actual.IsNil() {
+				d.report(path, recorded, actual)
+			}
+
+			return
+		}
+
+		v := visit{recorded.Pointer(), actual.Pointer(), recorded.Type()}
+		if d.visited[v] {
+			return
+		}
+		d.visited[v] = true
+
+		d.diff(path, fieldPath, fo, recorded.Elem(), actual.Elem())
+	case reflect.Interface:
+		d.diff(path, fieldPath, fo, recorded.Elem(), actual.Elem())
+	case reflect.Struct:
+		for i := 0; i < recorded.NumField(); i++ {
+			field := recorded.Type().Field(i)
+
+			tag, err := parseTag(field)
+			if err != nil {
+				d.err = err
+				return
+			}
+
+			fieldFieldPath := fieldPath + "." + field.Name
+			// fields that are never recorded are not compared either
+			if tag.ignore || tag.omit || d.opts.ignored[fieldFieldPath] {
+				continue
+			}
+
+			d.diff(path+"."+field.Name, fieldFieldPath, d.opts.forField(fieldFieldPath, tag),
+				recorded.Field(i), actual.Field(i))
+		}
+	case reflect.Slice, reflect.Array:
+		// comparison options only make values more equal, so equal slices of
+		// basic values, like large byte slices, are not compared element by
+		// element
+		if isBasicKind(recorded.Type().Elem().Kind()) && recorded.CanInterface() && actual.CanInterface() &&
+			reflect.DeepEqual(recorded.Interface(), actual.Interface()) {
+			return
+		}
+
+		if fo.unordered {
+			d.diffUnordered(path, fieldPath, fo, recorded, actual)
+			return
+		}
+
+		for i := 0; i < recorded.Len() || i < actual.Len(); i++ {
+			elemPath := fmt.Sprintf("%s[%d]", path, i)
+
+			switch {
+			case i >= recorded.Len():
+				d.report(elemPath, reflect.Value{}, actual.Index(i))
+			case i >= actual.Len():
+				d.report(elemPath, recorded.Index(i), reflect.Value{})
+			default:
+				d.diff(elemPath, fieldPath, fo, recorded.Index(i), actual.Index(i))
+			}
+		}
+	case reflect.Map:
+		for _, key := range mapKeys(recorded, actual) {
+			if fo.extraKeys && !recorded.MapIndex(key).IsValid() {
+				continue
+			}
+
+			keyPath := fmt.Sprintf("%s[%s]", path, formatValue(key))
+			d.diff(keyPath, fieldPath, fo, recorded.MapIndex(key), actual.MapIndex(key))
+		}
+	case reflect.Bool:
+		if recorded.Bool() != actual.Bool() {
+			d.report(path, recorded, actual)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if recorded.Int() != actual.Int() {
+			d.report(path, recorded, actual)
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if recorded.Uint() != actual.Uint() {
+			d.report(path, recorded, actual)
+		}
+	case reflect.Float32, reflect.Float64:
+		if !floatEqual(recorded.Float(), actual.Float()) && !(math.Abs(recorded.Float()-actual.Float()) <= fo.tolerance) {
+			d.report(path, recorded, actual)
+		}
+	case reflect.Complex64, reflect.Complex128:
+		rc, ac := recorded.Complex(), actual.Complex()
+		if !floatEqual(real(rc), real(ac)) || !floatEqual(imag(rc), imag(ac)) {
+			d.report(path, recorded, actual)
+		}
+	case reflect.String:
+		if recorded.String() != actual.String() {
+			d.report(path, recorded, actual)
+		}
+	default:
+		// funcs, channels and unsafe pointers can only be compared by address
+		if recorded.Pointer() != actual.Pointer() {
+			d.report(path, recorded, actual)
+		}
+	}
+}
+
+// diffUnordered compares slices or arrays as unordered sets. Every recorded
+// element is matched with an equal actual element, and elements without a
+// match are reported.
+func (d *differ) diffUnordered(path string, fieldPath string, fo fieldOptions, recorded reflect.Value, actual reflect.Value) {
+	matched := make([]bool, actual.Len())
+
+	for i := 0; i < recorded.Len(); i++ {
+		found := false
+		for j := 0; j < actual.Len() && !found; j++ {
+			if !matched[j] && d.equal(fieldPath, fo, recorded.Index(i), actual.Index(j)) {
+				matched[j], found = true, true
+			}
+		}
+
+		if !found {
+			d.report(fmt.Sprintf("%s[%d]", path, i), recorded.Index(i), reflect.Value{})
+		}
+	}
+
+	for j := 0; j < actual.Len(); j++ {
+		if !matched[j] {
+			d.report(fmt.Sprintf("%s[%d]", path, j), reflect.Value{}, actual.Index(j))
+		}
+	}
+}
+
+// floatEqual returns whether floats are equal, recorded NaN equals actual NaN
+func floatEqual(recorded float64, actual float64) bool {
+	return recorded == actual || math.IsNaN(recorded) && math.IsNaN(actual)
+}
+
+// equalMethod compares values using their Equal method, if type defines one
+func equalMethod(recorded reflect.Value, actual reflect.Value) (equal bool, ok bool) {
+	if !recorded.CanInterface() || !actual.CanInterface() {
+		return false, false
+	}
+
+	method := recorded.MethodByName("Equal")
+	if !method.IsValid() {
+		return false, false
+	}
+
+	methodType := method.Type()
+	if methodType.NumIn() != 1 || methodType.NumOut() != 1 ||
+		methodType.In(0) != recorded.Type() || methodType.Out(0).Kind() != reflect.Bool {
+		return false, false
+	}
+
+	return method.Call([]reflect.Value{actual})[0].Bool(), true
+}
+
+// mapKeys returns sorted union of keys of recorded and actual map
+func mapKeys(recorded reflect.Value, actual reflect.Value) []reflect.Value {
+	keys := recorded.MapKeys()
+	for _, key := range actual.MapKeys() {
+		if !recorded.MapIndex(key).IsValid() {
+			keys = append(keys, key)
+		}
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		return formatValue(keys[i]) < formatValue(keys[j])
+	})
+
+	return keys
+}
+
+// formatValue formats value for displaying in differences
+func formatValue(value reflect.Value) string {
+	if !value.IsValid() {
+		return "<missing>"
+	}
+
+	if value.Kind() == reflect.String {
+		return fmt.Sprintf("%q", value.String())
+	}
+
+	return fmt.Sprintf("%+v", value)
+}
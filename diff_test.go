@@ -0,0 +1,235 @@
+package testparrot
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiff(t *testing.T) {
+	type dog struct {
+		Name  string
+		Breed string
+	}
+
+	type kennel struct {
+		Dogs   []dog
+		Owners map[string]*dog
+		Opened time.Time
+		note   string
+	}
+
+	opened := time.Date(1999, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		recorded interface{}
+		actual   interface{}
+		expected []Difference
+	}{
+		{
+			name:     "equal",
+			recorded: kennel{Dogs: []dog{{"Fido", "Terrier"}}, Opened: opened},
+			actual:   kennel{Dogs: []dog{{"Fido", "Terrier"}}, Opened: opened.In(time.FixedZone("UTC-8", -8*60*60))},
+		},
+		{
+			name:     "nil",
+			recorded: nil,
+			actual:   nil,
+		},
+		{
+			name:     "literal",
+			recorded: "value1",
+			actual:   "value2",
+			expected: []Difference{{"", `"value1"`, `"value2"`}},
+		},
+		{
+			name:     "nan",
+			recorded: []float64{math.NaN()},
+			actual:   []float64{math.NaN()},
+		},
+		{
+			name:     "byte slices",
+			recorded: []byte("value1"),
+			actual:   []byte("value2"),
+			expected: []Difference{{"[5]", "49", "50"}},
+		},
+		{
+			name:     "equal byte slices",
+			recorded: []byte("value"),
+			actual:   []byte("value"),
+		},
+		{
+			name:     "nan and number",
+			recorded: math.NaN(),
+			actual:   1.0,
+			expected: []Difference{{"", "NaN", "1"}},
+		},
+		{
+			name:     "type mismatch",
+			recorded: 1,
+			actual:   "1",
+			expected: []Difference{{"", "1", `"1"`}},
+		},
+		{
+			name:     "slice element field",
+			recorded: kennel{Dogs: []dog{{"Fido", "Terrier"}, {"Mika", "Foxhound"}}},
+			actual:   kennel{Dogs: []dog{{"Fido", "Terrier"}, {"Mika", "Poodle"}}},
+			expected: []Difference{{".Dogs[1].Breed", `"Foxhound"`, `"Poodle"`}},
+		},
+		{
+			name:     "slice length",
+			recorded: []string{"a"},
+			actual:   []string{"a", "b"},
+			expected: []Difference{{"[1]", "<missing>", `"b"`}},
+		},
+		{
+			name:     "map values",
+			recorded: kennel{Owners: map[string]*dog{"john": {Name: "Fido"}, "jane": {Name: "Rex"}}},
+			actual:   kennel{Owners: map[string]*dog{"john": {Name: "Lido"}, "jim": {Name: "Rex"}}},
+			expected: []Difference{
+				{`.Owners["jane"]`, "&{Name:Rex Breed:}", "<missing>"},
+				{`.Owners["jim"]`, "<missing>", "&{Name:Rex Breed:}"},
+				{`.Owners["john"].Name`, `"Fido"`, `"Lido"`},
+			},
+		},
+		{
+			name:     "unexported field",
+			recorded: kennel{note: "a"},
+			actual:   kennel{note: "b"},
+			expected: []Difference{{".note", `"a"`, `"b"`}},
+		},
+		{
+			name:     "time",
+			recorded: kennel{Opened: opened},
+			actual:   kennel{Opened: opened.Add(time.Second)},
+			expected: []Difference{{".Opened", "1999-01-02 03:04:05 +0000 UTC", "1999-01-02 03:04:06 +0000 UTC"}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			diffs, err := Diff(test.recorded, test.actual)
+			require.NoError(t, err)
+			require.Equal(t, test.expected, diffs)
+		})
+	}
+}
+
+func TestDiffCycle(t *testing.T) {
+	type node struct {
+		Value int
+		Next  *node
+	}
+
+	recorded := &node{Value: 1}
+	recorded.Next = recorded
+
+	actual := &node{Value: 1}
+	actual.Next = actual
+
+	diffs, err := Diff(recorded, actual)
+	require.NoError(t, err)
+	require.Empty(t, diffs)
+}
+
+func TestDiffOptions(t *testing.T) {
+	type point struct {
+		X, Y float64
+	}
+
+	type measurement struct {
+		Value   float64           `testparrot:"approx=0.01"`
+		Points  []point           `testparrot:"unordered"`
+		Labels  map[string]string `testparrot:"extrakeys"`
+		Comment string            `testparrot:"ignore"`
+		Tags    []string
+		Meta    map[string]string
+	}
+
+	tests := []struct {
+		name     string
+		recorded interface{}
+		actual   interface{}
+		opts     []CompareOption
+		expected []Difference
+	}{
+		{
+			name:     "tags",
+			recorded: measurement{Value: 1.001, Points: []point{{1, 2}, {3, 4}}, Labels: map[string]string{"a": "1"}, Comment: "a"},
+			actual:   measurement{Value: 1.009, Points: []point{{3, 4}, {1, 2}}, Labels: map[string]string{"a": "1", "b": "2"}, Comment: "b"},
+		},
+		{
+			name:     "tags differences",
+			recorded: measurement{Value: 1, Points: []point{{1, 2}, {3, 4}}, Labels: map[string]string{"a": "1"}},
+			actual:   measurement{Value: 1.1, Points: []point{{3, 4}, {1, 3}}, Labels: map[string]string{"b": "2"}},
+			expected: []Difference{
+				{".Value", "1", "1.1"},
+				{".Points[0]", "{X:1 Y:2}", "<missing>"},
+				{".Points[1]", "<missing>", "{X:1 Y:3}"},
+				{`.Labels["a"]`, `"1"`, "<missing>"},
+			},
+		},
+		{
+			name:     "ignore fields",
+			recorded: measurement{Tags: []string{"a"}, Meta: map[string]string{"a": "1"}},
+			actual:   measurement{Tags: []string{"b"}, Meta: map[string]string{"a": "2"}},
+			opts:     []CompareOption{IgnoreFields("Tags", ".Meta")},
+		},
+		{
+			name:     "float tolerance",
+			recorded: []float64{1, 2},
+			actual:   []float64{1.05, 2.2},
+			opts:     []CompareOption{FloatTolerance(0.1)},
+			expected: []Difference{{"[1]", "2", "2.2"}},
+		},
+		{
+			name:     "float tolerance by path",
+			recorded: measurement{Value: 1},
+			actual:   measurement{Value: 1.5},
+			opts:     []CompareOption{FloatTolerance(1, ".Value")},
+		},
+		{
+			name:     "unordered slices",
+			recorded: measurement{Tags: []string{"a", "b", "b"}},
+			actual:   measurement{Tags: []string{"b", "a", "b"}},
+			opts:     []CompareOption{UnorderedSlices(".Tags")},
+		},
+		{
+			name:     "unordered slices with duplicates",
+			recorded: []string{"a", "b", "b"},
+			actual:   []string{"b", "a", "a"},
+			opts:     []CompareOption{UnorderedSlices()},
+			expected: []Difference{
+				{"[2]", `"b"`, "<missing>"},
+				{"[2]", "<missing>", `"a"`},
+			},
+		},
+		{
+			name:     "ignore extra keys",
+			recorded: map[string]int{"a": 1},
+			actual:   map[string]int{"a": 1, "b": 2},
+			opts:     []CompareOption{IgnoreExtraKeys()},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			diffs, err := Diff(test.recorded, test.actual, test.opts...)
+			require.NoError(t, err)
+			require.Equal(t, test.expected, diffs)
+		})
+	}
+
+	t.Run("invalid tag", func(t *testing.T) {
+		type invalid struct {
+			Value float64 `testparrot:"approx=abc"`
+		}
+
+		_, err := Diff([]invalid{{}}, []invalid{{}}, UnorderedSlices())
+		require.EqualError(t, err,
+			`testparrot: invalid approx option of field 'Value': strconv.ParseFloat: parsing "abc": invalid syntax`)
+	})
+}
@@ -0,0 +1,92 @@
+package testparrot
+
+import (
+	"fmt"
+	"testing"
+)
+
+// Expect method records actual value under specified key and compares it with
+// recorded value. When values differ, test fails with a list of differences
+// between them. Values are compared with comparison options of recorder and
+// opts. Expect returns whether values are equal.
+func (r *Recorder) Expect(t testing.TB, key interface{}, actual interface{}, opts ...CompareOption) bool {
+	t.Helper()
+
+	recorded, err := r.recordKey(t, key, actual)
+	if err != nil {
+		r.fail(t, err)
+		return false
+	}
+
+	return r.expect(t, key, recorded, actual, opts)
+}
+
+// ExpectNext method records actual value as next value in sequence and
+// compares it with recorded value. When values differ, test fails with a list
+// of differences between them. Values are compared with comparison options of
+// recorder and opts. ExpectNext returns whether values are equal.
+func (r *Recorder) ExpectNext(t testing.TB, actual interface{}, opts ...CompareOption) bool {
+	t.Helper()
+
+	key, recorded, err := r.recordNext(t, actual)
+	if err != nil {
+		r.fail(t, err)
+		return false
+	}
+
+	return r.expect(t, key, recorded, actual, opts)
+}
+
+func (r *Recorder) expect(t testing.TB, key interface{}, recorded interface{}, actual interface{}, opts []CompareOption) bool {
+	t.Helper()
+
+	// actual value is normalized and scrubbed like recording, so volatile
+	// and redacted values of recording equal any actual value
+	diffs, err := r.Compare(recorded, actual, opts...)
+	if err != nil {
+		r.fail(t, err)
+		return false
+	}
+
+	if len(diffs) == 0 {
+		return true
+	}
+
+	t.Errorf("%v", newErr(fmt.Errorf(
+		"value differs from recording with key '%v' for test '%s':\n%s",
+		key, t.Name(), formatDiff(diffs))))
+
+	return false
+}
+
+// SetCompareOptions method sets comparison options, which are used by all
+// comparisons of recorder, like in Expect and ExpectInline
+func (r *Recorder) SetCompareOptions(opts ...CompareOption) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.compareOptions = opts
+}
+
+// Compare method returns differences between recorded and actual value, like
+// Diff, or an error if values cannot be compared. Both values are normalized
+// and scrubbed first, so volatile and redacted values of recording equal any
+// actual value. Values are compared with comparison options of recorder and
+// opts, which take precedence.
+func (r *Recorder) Compare(recorded interface{}, actual interface{}, opts ...CompareOption) ([]Difference, error) {
+	recorded, err := r.clean(recorded)
+	if err != nil {
+		return nil, err
+	}
+
+	actual, err = r.clean(actual)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	opts = append(append([]CompareOption(nil), r.compareOptions...), opts...)
+	r.mu.Unlock()
+
+	return Diff(recorded, actual, opts...)
+}
@@ -0,0 +1,98 @@
+package testparrot
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecorderExpect(t *testing.T) {
+	t.Run("recording enabled", func(t *testing.T) {
+		recorder := NewRecorder()
+		recorder.EnableRecording(true)
+		require.True(t, recorder.Expect(t, "key", testStruct{V1: "value"}))
+		require.Contains(t, recorder.allRecordings[t.Name()], Recording{"key", testStruct{V1: "value"}})
+	})
+
+	t.Run("replay equal values", func(t *testing.T) {
+		recorder := NewRecorder()
+		recorder.Load(t.Name(), []Recording{{"key", testStruct{V1: "value"}}})
+		require.True(t, recorder.Expect(t, "key", testStruct{V1: "value"}))
+	})
+
+	t.Run("replay different values", func(t *testing.T) {
+		recorder := NewRecorder()
+		recorder.Load(t.Name(), []Recording{{"key", testStruct{V3: &testStruct{V4: []string{"a", "b"}}}}})
+
+		ft := &fakeTB{TB: t}
+		require.False(t, recorder.Expect(ft, "key", testStruct{V3: &testStruct{V4: []string{"a", "c"}}}))
+		require.Equal(t, []string{
+			"testparrot: value differs from recording with key 'key' for test '" + t.Name() + "':\n" +
+				"\t.V3.V4[1]: recorded \"b\", actual \"c\"",
+		}, ft.failures)
+	})
+}
+
+func TestRecorderExpectNext(t *testing.T) {
+	recorder := NewRecorder()
+	recorder.Load(t.Name(), []Recording{{0, "value1"}, {1, "value2"}})
+
+	ft := &fakeTB{TB: t}
+	require.True(t, recorder.ExpectNext(ft, "value1"))
+	require.False(t, recorder.ExpectNext(ft, "value3"))
+	require.Equal(t, []string{
+		"testparrot: value differs from recording with key '1' for test '" + t.Name() + "':\n" +
+			"\t.: recorded \"value2\", actual \"value3\"",
+	}, ft.failures)
+}
+
+func TestRecorderExpectOptions(t *testing.T) {
+	t.Run("call options", func(t *testing.T) {
+		recorder := NewRecorder()
+		recorder.Load(t.Name(), []Recording{{"key", testStruct{V1: "value", V4: []string{"a", "b"}}}})
+		require.True(t, recorder.Expect(t, "key", testStruct{V1: "other", V4: []string{"b", "a"}},
+			IgnoreFields(".V1"), UnorderedSlices()))
+	})
+
+	t.Run("recorder options", func(t *testing.T) {
+		recorder := NewRecorder()
+		recorder.SetCompareOptions(IgnoreFields(".V1"))
+		recorder.Load(t.Name(), []Recording{{0, testStruct{V1: "value", V4: []string{"a", "b"}}}})
+
+		ft := &fakeTB{TB: t}
+		require.False(t, recorder.ExpectNext(ft, testStruct{V1: "other", V4: []string{"b", "a"}}))
+		require.Equal(t, []string{
+			"testparrot: value differs from recording with key '0' for test '" + t.Name() + "':\n" +
+				"\t.V4[0]: recorded \"a\", actual \"b\"\n" +
+				"\t.V4[1]: recorded \"b\", actual \"a\"",
+		}, ft.failures)
+	})
+
+	t.Run("invalid tag", func(t *testing.T) {
+		type invalid struct {
+			Value float64 `testparrot:"approx=abc"`
+		}
+
+		recorder := NewRecorder()
+		recorder.Load(t.Name(), []Recording{{"key", invalid{}}})
+
+		ft := &fakeTB{TB: t}
+		require.False(t, recorder.Expect(ft, "key", invalid{}))
+		require.Equal(t, []string{
+			`testparrot: invalid approx option of field 'Value': strconv.ParseFloat: parsing "abc": invalid syntax`,
+		}, ft.failures)
+	})
+
+	t.Run("compare", func(t *testing.T) {
+		recorder := NewRecorder()
+		recorder.SetCompareOptions(FloatTolerance(0.1))
+
+		diffs, err := recorder.Compare(1.0, 1.05)
+		require.NoError(t, err)
+		require.Empty(t, diffs)
+
+		diffs, err = recorder.Compare(1.0, 1.05, FloatTolerance(0.01))
+		require.NoError(t, err)
+		require.Equal(t, []Difference{{"", "1", "1.05"}}, diffs)
+	})
+}
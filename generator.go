@@ -1,10 +1,14 @@
 package testparrot
 
 import (
+	"bytes"
 	"encoding"
+	"encoding/gob"
 	"encoding/json"
 	"fmt"
 	"io"
+	"io/ioutil"
+	"math"
 	"os"
 	"reflect"
 	"sort"
@@ -13,6 +17,7 @@ import (
 	"unicode/utf8"
 
 	. "github.com/dave/jennifer/jen"
+	"github.com/pmezard/go-difflib/difflib"
 )
 
 const (
@@ -33,6 +38,18 @@ type Generator struct {
 
 	// Name of generate package
 	pkgName string
+
+	// blobs defines how large values are stored in blob files, nil if
+	// they are inlined
+	blobs *blobOptions
+
+	// pendingBlobs defines blob files of values in generated code, which
+	// are written together with generated code
+	pendingBlobs map[string][]byte
+
+	// constructors defines constructors of types with unexported fields,
+	// registered on recorder whose recordings are generated
+	constructors map[reflect.Type]constructor
 }
 
 func NewGenerator(pkgPath, pkgName string) *Generator {
@@ -49,21 +66,52 @@ func (g *Generator) GenerateToFile(recorder *Recorder, opts GenOptions, filePath
 		return err
 	}
 
+	if err := g.writeBlobs(); err != nil {
+		return err
+	}
+
 	return file.Close()
 }
 
+// Diff renders generated code and returns unified diff between file and
+// generated code, without writing anything. Empty diff is returned if file
+// is up to date. Missing file is compared as an empty file.
+func (g *Generator) Diff(recorder *Recorder, opts GenOptions, filePath string) (string, error) {
+	generated := &bytes.Buffer{}
+	if err := g.Generate(recorder, opts, generated); err != nil {
+		return "", err
+	}
+
+	existing, err := ioutil.ReadFile(filePath)
+	if err != nil && !os.IsNotExist(err) {
+		return "", err
+	}
+
+	return difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(string(existing)),
+		B:        difflib.SplitLines(generated.String()),
+		FromFile: filePath,
+		ToFile:   filePath + " (recorded)",
+		Context:  3,
+	})
+}
+
 func (g *Generator) Generate(recorder *Recorder, opts GenOptions, out io.Writer) error {
 	f := NewFilePathName(g.pkgPath, g.pkgName)
 
-	f.HeaderComment(headerComment)
+	// only blob files of values in this file are written
+	g.pendingBlobs = nil
 
-	keys := make([]string, 0, len(recorder.allRecordings))
+	g.constructors = recorder.getConstructors()
 
-	allRecordings := recorder.allRecordings
+	f.HeaderComment(headerComment)
+
+	allRecordings := recorder.recordings()
 	if opts.Filter != nil {
 		allRecordings = opts.Filter(allRecordings)
 	}
 
+	keys := make([]string, 0, len(allRecordings))
 	for key := range allRecordings {
 		keys = append(keys, key)
 	}
@@ -159,13 +207,24 @@ func sliceToCode(g *Generator, sliceVal reflect.Value, parent reflect.Value) (Co
 	typ := sliceVal.Type()
 	elemType := typ.Elem()
 
-	a := sliceVal.Type().Name()
-	fmt.Println(a)
-
 	var litValue Code
 	var values []Code
 	var err error
 
+	// large byte slices are stored in blob files
+	if sliceVal.Kind() == reflect.Slice && elemType.Kind() == reflect.Uint8 {
+		blob, err := blobToCode(g, sliceVal.Bytes())
+		if err != nil {
+			return nil, err
+		}
+
+		if blob != nil && typ.Name() != "" {
+			return typeToCode(g, typ).Call(blob), nil
+		} else if blob != nil {
+			return blob, nil
+		}
+	}
+
 	if sliceVal.Kind() == reflect.Slice && elemType.Kind() == reflect.Uint8 && typ.Name() != "" {
 		s := reflect.New(reflect.SliceOf(elemType)).Elem()
 		s = reflect.AppendSlice(s, sliceVal)
@@ -270,32 +329,56 @@ func structToCode(g *Generator, structVal reflect.Value, parent reflect.Value) (
 	}
 
 	values := Dict{}
+	lost := []string{}
 	for i := 0; i < structType.NumField(); i++ {
 		fieldType := structType.Field(i)
 		fieldVal := structVal.Field(i)
 
-		// if field is private, we cannot set it
+		// if field is private, we cannot set it, so value would be lost
+		// unless it is zero
 		if unicode.IsLower(rune(fieldType.Name[0])) {
+			if !fieldVal.IsZero() {
+				lost = append(lost, fieldType.Name)
+			}
+
 			continue
 		}
 
-		// only set value if it is not zero
-		if fieldVal.IsZero() {
+		tag, err := parseTag(fieldType)
+		if err != nil {
+			return nil, err
+		}
+
+		if tag.omit {
 			continue
 		}
 
-		if fieldVal.Kind() == reflect.Ptr && fieldVal.IsNil() {
+		if tag.redact {
+			fieldVal = placeholder(fieldType.Type, Redacted)
+		}
+
+		// only set value if it is not zero, unless zero value is kept
+		// explicitly
+		if fieldVal.IsZero() && !tag.keepZero {
 			continue
 		}
 
-		code, err := valToCode(g, fieldVal, structVal)
-		if err != nil {
+		var code Code
+		if isNil(fieldVal) {
+			code = Nil()
+		} else if code, err = valToCode(g, fieldVal, structVal); err != nil {
 			return nil, err
 		}
 
 		values[Id(fieldType.Name)] = code
 	}
 
+	if len(lost) > 0 {
+		return nil, fmt.Errorf(
+			"cannot record unexported fields %s of type '%v', register its constructor with RegisterConstructor "+
+				"or implement encoding.TextMarshaler", strings.Join(lost, ", "), structType)
+	}
+
 	// if parent is a slice and struct type is same as slice type, we can omit struct type
 	if parent.IsValid() && parent.Kind() == reflect.Slice {
 		elemType := parent.Type().Elem()
@@ -380,6 +463,16 @@ func ptrToCode(g *Generator, ptrVal reflect.Value, parent reflect.Value) (Code,
 }
 
 func strToCode(g *Generator, val string) (Code, error) {
+	// large strings are stored in blob files
+	blob, err := blobToCode(g, []byte(val))
+	if err != nil {
+		return nil, err
+	}
+
+	if blob != nil {
+		return String().Call(blob), nil
+	}
+
 	// split longer strings as multiline strings
 	hasNewlines := strings.Count(val, "\n") > 0
 	hasOnlyNewlineAtEnd := strings.Count(val, "\n") == 1 && strings.HasSuffix(val, "\n")
@@ -453,11 +546,39 @@ func litToCode(g *Generator, value reflect.Value) (Code, error) {
 	switch val.(type) {
 	case string:
 		return strToCode(g, val.(string))
+	case float32, float64:
+		return floatToCode(value), nil
 	default:
 		return Lit(val), nil
 	}
 }
 
+// floatToCode generates float literal, NaN and infinities have no literals,
+// so they are generated with functions of math package
+func floatToCode(value reflect.Value) Code {
+	var code *Statement
+
+	switch f := value.Float(); {
+	case math.IsNaN(f):
+		code = Qual("math", "NaN").Call()
+	case math.IsInf(f, 0):
+		sign := 1
+		if f < 0 {
+			sign = -1
+		}
+
+		code = Qual("math", "Inf").Call(Lit(sign))
+	default:
+		return Lit(value.Interface())
+	}
+
+	if value.Kind() == reflect.Float32 {
+		return Float32().Call(code)
+	}
+
+	return code
+}
+
 func marshalersToCode(g *Generator, value reflect.Value, parent reflect.Value) (Code, error) {
 	switch v := value.Interface().(type) {
 	case encoding.TextMarshaler:
@@ -495,12 +616,34 @@ func marshalersToCode(g *Generator, value reflect.Value, parent reflect.Value) (
 			return nil, err
 		}
 
+		return decodeValueToCode(g, litValue, value), nil
+	case gob.GobEncoder:
+		data, err := v.GobEncode()
+		if err != nil {
+			return nil, err
+		}
+
+		litValue, err := valToCode(g, reflect.ValueOf(data), value)
+		if err != nil {
+			return nil, err
+		}
+
 		return decodeValueToCode(g, litValue, value), nil
 	default:
 		return nil, nil
 	}
 }
 
+// isNil returns whether value is nil, as nil values have no literals
+func isNil(value reflect.Value) bool {
+	switch value.Kind() {
+	case reflect.Ptr, reflect.Map, reflect.Slice, reflect.Interface, reflect.Chan, reflect.Func:
+		return value.IsNil()
+	default:
+		return false
+	}
+}
+
 func isEmptyStructSkipPrivateFields(structValue reflect.Value) bool {
 	if structValue.Kind() == reflect.Ptr {
 		structValue = structValue.Elem()
@@ -530,7 +673,13 @@ func valToCode(g *Generator, value reflect.Value, parent reflect.Value) (Code, e
 		return Nil(), nil
 	}
 
-	code, err := marshalersToCode(g, value, parent)
+	// registered constructors take precedence over serialized values
+	code, err := constructorToCode(g, value)
+	if code != nil || err != nil {
+		return code, err
+	}
+
+	code, err = marshalersToCode(g, value, parent)
 	if code != nil || err != nil {
 		return code, err
 	}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"fmt"
 	"io/ioutil"
+	"math"
 	"os"
 	"path"
 	"reflect"
@@ -73,6 +74,44 @@ func TestGenerate(t *testing.T) {
 	})
 }
 
+func TestGeneratorDiff(t *testing.T) {
+	recorder := NewRecorder()
+	recorder.Load("recorder1", []Recording{{"key1", "value1"}})
+
+	generator := NewGenerator(pkgPath, pkgName)
+	opts := GenOptions{RecorderVar: "recorder"}
+	genPath := path.Join(t.TempDir(), "gen.go")
+
+	t.Run("missing file", func(t *testing.T) {
+		diff, err := generator.Diff(recorder, opts, genPath)
+		require.NoError(t, err)
+		require.Contains(t, diff, "+\trecorder.Load(\"recorder1\"")
+	})
+
+	t.Run("up to date", func(t *testing.T) {
+		require.NoError(t, generator.GenerateToFile(recorder, opts, genPath))
+
+		diff, err := generator.Diff(recorder, opts, genPath)
+		require.NoError(t, err)
+		require.Empty(t, diff)
+	})
+
+	t.Run("outdated", func(t *testing.T) {
+		recorder := NewRecorder()
+		recorder.Load("recorder1", []Recording{{"key1", "value2"}})
+
+		diff, err := generator.Diff(recorder, opts, genPath)
+		require.NoError(t, err)
+		require.Contains(t, diff, "-\t\tValue: \"value1\",\n")
+		require.Contains(t, diff, "+\t\tValue: \"value2\",\n")
+
+		// file is not changed
+		contents, err := ioutil.ReadFile(genPath)
+		require.NoError(t, err)
+		require.Contains(t, string(contents), "value1")
+	})
+}
+
 func TestValToCode(t *testing.T) {
 	type wrappedBytes []byte
 
@@ -100,6 +139,15 @@ func TestValToCode(t *testing.T) {
 
 	type Enum string
 
+	type taggedStruct struct {
+		Name     string
+		Password string   `testparrot:"-"`
+		Token    string   `testparrot:"redact"`
+		Secret   *Value   `testparrot:"redact"`
+		Count    int      `testparrot:"keepzero"`
+		Tags     []string `testparrot:"keepzero"`
+	}
+
 	tests := []struct {
 		name     string
 		value    interface{}
@@ -239,6 +287,16 @@ func TestValToCode(t *testing.T) {
 			},
 			expected: "[]interface{}{gotestparrot.Decode(\"1999-01-02T03:04:05Z\", time.Time{}).(time.Time)}",
 		},
+		{
+			name:     "nan",
+			value:    math.NaN(),
+			expected: "math.NaN()",
+		},
+		{
+			name:     "negative infinity float32",
+			value:    float32(math.Inf(-1)),
+			expected: "float32(math.Inf(-1))",
+		},
 		{
 			name:     "enum",
 			value:    Enum("test"),
@@ -275,6 +333,21 @@ func TestValToCode(t *testing.T) {
 				"`json:\"key2\"`\n\tField3 []struct {\n\t\tField1 string\n\t\tField2 int\n\t}\n\tField4 *struct {\n\t\tField1 " +
 				"int\n\t}\n\tField5 Value\n\tField6 **[]Value\n\tField7 struct {\n\t\tValue\n\t\tValue2 Value\n\t}\n}{}",
 		},
+		{
+			name:     "sequence key",
+			value:    SeqKey{Seq: "seq", Index: 1},
+			expected: "SeqKey{\n\tIndex: 1,\n\tSeq:   \"seq\",\n}",
+		},
+		{
+			name: "tagged struct",
+			value: taggedStruct{
+				Name:     "name",
+				Password: "password",
+				Token:    "token",
+				Secret:   &Value{V1: "secret"},
+			},
+			expected: "taggedStruct{\n\tCount: 0,\n\tName:  \"name\",\n\tTags:  nil,\n\tToken: \"REDACTED\",\n}",
+		},
 		{
 			name:     "anonymous slice struct",
 			value:    []struct{ Field1 string }{},
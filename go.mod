@@ -1,11 +1,13 @@
 module github.com/xtruder/go-testparrot
 
-go 1.15
+go 1.18
 
 require (
 	github.com/dave/jennifer v1.5.0
-	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/google/uuid v1.3.0
+	github.com/pmezard/go-difflib v1.0.0
 	github.com/stretchr/testify v1.7.0
-	gopkg.in/yaml.v3 v3.0.0-20210107192922-496545a6307b // indirect
+	gopkg.in/yaml.v3 v3.0.0-20210107192922-496545a6307b
 )
+
+require github.com/davecgh/go-spew v1.1.1 // indirect
@@ -0,0 +1,525 @@
+package testparrot
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/pmezard/go-difflib/difflib"
+	"gopkg.in/yaml.v3"
+)
+
+// Format defines format in which recordings are stored
+type Format int
+
+const (
+	// FormatGo stores recordings as generated go code
+	FormatGo Format = iota
+
+	// FormatJSON stores recordings of every test as JSON golden file
+	FormatJSON
+
+	// FormatYAML stores recordings of every test as YAML golden file
+	FormatYAML
+)
+
+// formatNames defines names of formats, as used in flags and as extensions
+// of golden files
+var formatNames = map[Format]string{
+	FormatGo:   "go",
+	FormatJSON: "json",
+	FormatYAML: "yaml",
+}
+
+func (f Format) String() string {
+	if name, ok := formatNames[f]; ok {
+		return name
+	}
+
+	return fmt.Sprintf("Format(%d)", int(f))
+}
+
+// ParseFormat parses recording format from its name
+func ParseFormat(name string) (Format, error) {
+	for format, formatName := range formatNames {
+		if formatName == name {
+			return format, nil
+		}
+	}
+
+	return FormatGo, fmt.Errorf("unknown recording format '%s'", name)
+}
+
+// GoldenDir defines directory, relative to package directory, where golden
+// files are stored
+const GoldenDir = "testdata/testparrot"
+
+// goldenFile defines contents of a golden file with recordings of a test
+type goldenFile struct {
+	// Test defines name of a test
+	Test string `json:"test"`
+
+	// Recordings defines recordings of a test
+	Recordings []goldenRecording `json:"recordings"`
+}
+
+// goldenRecording defines a single recording in a golden file. Types of keys
+// and values are stored next to them, so values can be decoded into their
+// original types.
+type goldenRecording struct {
+	Key     json.RawMessage `json:"key"`
+	KeyType string          `json:"keyType"`
+	Type    string          `json:"type,omitempty"`
+	Value   json.RawMessage `json:"value"`
+}
+
+// rawValue defines value loaded from golden file, which was not decoded yet.
+// Values are decoded when they are replayed, into type of value passed by
+// test.
+type rawValue struct {
+	typ  string
+	data json.RawMessage
+}
+
+// rawKey defines key of a type that cannot be decoded without knowing it, it
+// equals keys with same type and encoding
+type rawKey struct {
+	typ  string
+	data string
+}
+
+func (k rawKey) String() string {
+	return k.data
+}
+
+// goldenTypes defines types that can be decoded without a value of the same
+// type, by their names
+var goldenTypes = map[string]reflect.Type{}
+
+func init() {
+	for _, value := range []interface{}{
+		false, "", int(0), int8(0), int16(0), int32(0), int64(0),
+		uint(0), uint8(0), uint16(0), uint32(0), uint64(0),
+		float32(0), float64(0), SeqKey{},
+	} {
+		goldenTypes[reflect.TypeOf(value).String()] = reflect.TypeOf(value)
+	}
+}
+
+// typeName returns name of type of a value, as stored in golden files
+func typeName(value interface{}) string {
+	if value == nil {
+		return ""
+	}
+
+	return reflect.TypeOf(value).String()
+}
+
+// decodeKey decodes key of a recording, keys of unknown types are kept raw
+func decodeKey(typ string, data json.RawMessage) (interface{}, error) {
+	if typ == "" {
+		return nil, nil
+	}
+
+	keyType, ok := goldenTypes[typ]
+	if !ok {
+		return rawKey{typ, string(data)}, nil
+	}
+
+	key := reflect.New(keyType)
+	if err := json.Unmarshal(data, key.Interface()); err != nil {
+		return nil, err
+	}
+
+	return key.Elem().Interface(), nil
+}
+
+// keysEqual checks whether recorded key equals key
+func keysEqual(recorded interface{}, key interface{}) bool {
+	raw, ok := recorded.(rawKey)
+	if !ok {
+		return recorded == key
+	}
+
+	data, err := json.Marshal(key)
+	if err != nil {
+		return false
+	}
+
+	return raw.typ == typeName(key) && raw.data == string(data)
+}
+
+// decode decodes raw value into type of hint. If hint is nil or of another
+// type, value is decoded into type it was recorded with, if it is known, or
+// into generic JSON types.
+func (v *rawValue) decode(hint interface{}) (interface{}, error) {
+	if v.typ == "" {
+		return nil, nil
+	}
+
+	valueType, ok := goldenTypes[v.typ]
+	if hint != nil && typeName(hint) == v.typ {
+		valueType, ok = reflect.TypeOf(hint), true
+	}
+
+	if !ok {
+		var value interface{}
+		err := json.Unmarshal(v.data, &value)
+		return value, err
+	}
+
+	value := reflect.New(valueType)
+	if err := json.Unmarshal(v.data, value.Interface()); err != nil {
+		return nil, err
+	}
+
+	return value.Elem().Interface(), nil
+}
+
+// GoldenStorage stores recordings of every test in a separate JSON or YAML
+// golden file. Values are decoded when they are replayed, into type of value
+// passed by test.
+type GoldenStorage struct {
+	// dir defines directory with golden files
+	dir string
+
+	// format defines format of golden files
+	format Format
+
+	// check defines whether golden files are compared with recordings,
+	// instead of being written
+	check bool
+
+	// diffs defines diffs of outdated golden files in check mode
+	diffs []string
+}
+
+// NewGoldenStorage creates a new GoldenStorage, which stores golden files in
+// JSON or YAML format in dir
+func NewGoldenStorage(dir string, format Format) *GoldenStorage {
+	return &GoldenStorage{dir: dir, format: format}
+}
+
+// goldenPath returns path of golden file of a test. Subtests are stored in
+// subdirectories of their parent tests.
+func (s *GoldenStorage) goldenPath(name string) string {
+	segments := strings.Split(name, "/")
+	for i, segment := range segments {
+		segments[i] = escapeFilename(segment)
+	}
+
+	return filepath.Join(s.dir, filepath.Join(segments...)+"."+s.format.String())
+}
+
+// escapeFilename escapes characters which are not allowed in filenames on
+// some systems
+func escapeFilename(name string) string {
+	var b strings.Builder
+	for _, r := range name {
+		if r == '%' || strings.ContainsRune(`<>:"\|?*`, r) || r < ' ' {
+			fmt.Fprintf(&b, "%%%02X", r)
+			continue
+		}
+
+		b.WriteRune(r)
+	}
+
+	return b.String()
+}
+
+// Load method loads recordings of a test from its golden file. Nil
+// recordings are returned if test has no golden file.
+func (s *GoldenStorage) Load(name string) ([]Recording, error) {
+	data, err := ioutil.ReadFile(s.goldenPath(name))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	if s.format == FormatYAML {
+		if data, err = yamlToJSON(data); err != nil {
+			return nil, err
+		}
+	}
+
+	file := goldenFile{}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("%s: %v", s.goldenPath(name), err)
+	}
+
+	recordings := []Recording{}
+	for _, recording := range file.Recordings {
+		key, err := decodeKey(recording.KeyType, recording.Key)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", s.goldenPath(name), err)
+		}
+
+		recordings = append(recordings, Recording{
+			Key:   key,
+			Value: &rawValue{recording.Type, recording.Value},
+		})
+	}
+
+	return recordings, nil
+}
+
+// render renders golden file with recordings of a test
+func (s *GoldenStorage) render(name string, recordings []Recording) ([]byte, error) {
+	file := goldenFile{Test: name, Recordings: []goldenRecording{}}
+	for _, recording := range recordings {
+		key, err := json.Marshal(recording.Key)
+		if err != nil {
+			return nil, err
+		}
+
+		golden := goldenRecording{Key: key, KeyType: typeName(recording.Key)}
+		if raw, ok := recording.Key.(rawKey); ok {
+			golden.Key, golden.KeyType = json.RawMessage(raw.data), raw.typ
+		}
+
+		// values that were not replayed are stored as they were loaded
+		if raw, ok := recording.Value.(*rawValue); ok {
+			golden.Type, golden.Value = raw.typ, raw.data
+		} else {
+			golden.Type = typeName(recording.Value)
+			if golden.Value, err = json.Marshal(recording.Value); err != nil {
+				return nil, err
+			}
+		}
+
+		file.Recordings = append(file.Recordings, golden)
+	}
+
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	if s.format == FormatYAML {
+		return jsonToYAML(data)
+	}
+
+	return append(data, '\n'), nil
+}
+
+// Save method saves recordings of a test into its golden file, or removes
+// golden file if there are no recordings. In check mode golden file is only
+// compared with recordings.
+func (s *GoldenStorage) Save(name string, recordings []Recording) error {
+	if s.check {
+		diff, err := s.diff(name, recordings)
+		if diff != "" {
+			s.diffs = append(s.diffs, diff)
+		}
+
+		return err
+	}
+
+	goldenPath := s.goldenPath(name)
+
+	if len(recordings) == 0 {
+		if err := os.Remove(goldenPath); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+
+		return nil
+	}
+
+	data, err := s.render(name, recordings)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(goldenPath), 0770); err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(goldenPath, data, 0660)
+}
+
+// diff returns unified diff between golden file of a test and recordings,
+// empty if golden file is up to date
+func (s *GoldenStorage) diff(name string, recordings []Recording) (string, error) {
+	goldenPath := s.goldenPath(name)
+
+	existing, err := ioutil.ReadFile(goldenPath)
+	if err != nil && !os.IsNotExist(err) {
+		return "", err
+	}
+
+	var rendered []byte
+	if len(recordings) > 0 {
+		if rendered, err = s.render(name, recordings); err != nil {
+			return "", err
+		}
+	}
+
+	return difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(string(existing)),
+		B:        difflib.SplitLines(string(rendered)),
+		FromFile: goldenPath,
+		ToFile:   goldenPath + " (recorded)",
+		Context:  3,
+	})
+}
+
+// List method returns names of all tests with golden files, sorted by name
+func (s *GoldenStorage) List() ([]string, error) {
+	ext := "." + s.format.String()
+
+	names := []string{}
+	err := filepath.Walk(s.dir, func(path string, info os.FileInfo, err error) error {
+		if os.IsNotExist(err) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		if info.IsDir() || !strings.HasSuffix(path, ext) {
+			return nil
+		}
+
+		rel, err := filepath.Rel(s.dir, strings.TrimSuffix(path, ext))
+		if err != nil {
+			return err
+		}
+
+		names = append(names, unescapeFilename(filepath.ToSlash(rel)))
+
+		return nil
+	})
+
+	sort.Strings(names)
+
+	return names, err
+}
+
+// SetCheck method enables check mode, in which golden files are only
+// compared with recordings
+func (s *GoldenStorage) SetCheck(check bool) {
+	s.check = check
+}
+
+// CheckDiffs method returns diffs of outdated golden files in check mode
+func (s *GoldenStorage) CheckDiffs() []string {
+	return s.diffs
+}
+
+// Losses method returns parts of value that would not equal recorded value
+// after value is stored in golden file and decoded into its type, like
+// unexported fields, values that cannot be encoded, or values in interfaces,
+// which are decoded as generic JSON values
+func (s *GoldenStorage) Losses(value interface{}) []Loss {
+	if value == nil {
+		return nil
+	}
+
+	decoded, err := s.roundTrip(value)
+	if err != nil {
+		return []Loss{{Reason: fmt.Sprintf("cannot be stored as %s: %v", strings.ToUpper(s.format.String()), err)}}
+	}
+
+	diffs, err := Diff(value, decoded)
+	if err != nil {
+		return []Loss{{Reason: err.Error()}}
+	}
+
+	var losses []Loss
+	for _, diff := range diffs {
+		reason := fmt.Sprintf("recorded %s, replayed %s", diff.Recorded, diff.Actual)
+		if diff.Recorded == diff.Actual {
+			reason = fmt.Sprintf("%s is replayed with another type", diff.Recorded)
+		}
+
+		losses = append(losses, Loss{Path: diff.Path, Reason: reason})
+	}
+
+	return losses
+}
+
+// roundTrip encodes value like it is stored in golden file and decodes it
+// back, like when it is replayed
+func (s *GoldenStorage) roundTrip(value interface{}) (interface{}, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.format == FormatYAML {
+		if data, err = jsonToYAML(data); err != nil {
+			return nil, err
+		}
+
+		if data, err = yamlToJSON(data); err != nil {
+			return nil, err
+		}
+	}
+
+	return (&rawValue{typeName(value), data}).decode(value)
+}
+
+// unescapeFilename reverts escaping of filename
+func unescapeFilename(name string) string {
+	var b strings.Builder
+	for i := 0; i < len(name); i++ {
+		var r int
+		if name[i] == '%' && i+2 < len(name) {
+			if _, err := fmt.Sscanf(name[i+1:i+3], "%02X", &r); err == nil {
+				b.WriteByte(byte(r))
+				i += 2
+				continue
+			}
+		}
+
+		b.WriteByte(name[i])
+	}
+
+	return b.String()
+}
+
+// jsonToYAML converts JSON document to YAML, so YAML golden files keep same
+// encoding of values and order of fields as JSON golden files
+func jsonToYAML(data []byte) ([]byte, error) {
+	// JSON is valid YAML, so it can be parsed as YAML document
+	node := &yaml.Node{}
+	if err := yaml.Unmarshal(data, node); err != nil {
+		return nil, err
+	}
+
+	resetStyle(node)
+
+	buf := &bytes.Buffer{}
+	enc := yaml.NewEncoder(buf)
+	enc.SetIndent(2)
+
+	if err := enc.Encode(node); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), enc.Close()
+}
+
+// resetStyle resets JSON flow style of YAML nodes to default block style
+func resetStyle(node *yaml.Node) {
+	node.Style = 0
+	for _, child := range node.Content {
+		resetStyle(child)
+	}
+}
+
+// yamlToJSON converts YAML golden file to JSON
+func yamlToJSON(data []byte) ([]byte, error) {
+	var value interface{}
+	if err := yaml.Unmarshal(data, &value); err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(value)
+}
@@ -0,0 +1,187 @@
+package testparrot
+
+import (
+	"io/ioutil"
+	"math"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type goldenKey string
+
+func TestParseFormat(t *testing.T) {
+	format, err := ParseFormat("yaml")
+	require.NoError(t, err)
+	require.Equal(t, FormatYAML, format)
+
+	_, err = ParseFormat("xml")
+	require.EqualError(t, err, "unknown recording format 'xml'")
+}
+
+func TestGoldenStorage(t *testing.T) {
+	recordings := []Recording{
+		{"struct", testStruct{V1: "value", V3: &testStruct{V4: []string{"a"}}}},
+		{0, []byte("bytes")},
+		{SeqKey{Seq: "seq"}, int64(5)},
+		{goldenKey("custom"), time.Date(1999, 1, 2, 3, 4, 5, 0, time.UTC)},
+		{"nil", nil},
+	}
+
+	for _, format := range []Format{FormatJSON, FormatYAML} {
+		t.Run(format.String(), func(t *testing.T) {
+			storage := NewGoldenStorage(t.TempDir(), format)
+			require.NoError(t, storage.Save("TestA/sub case", recordings))
+			require.FileExists(t, filepath.Join(storage.dir, "TestA", "sub case."+format.String()))
+
+			loaded, err := storage.Load("TestA/sub case")
+			require.NoError(t, err)
+			require.Len(t, loaded, len(recordings))
+
+			for i, recording := range recordings {
+				require.True(t, keysEqual(loaded[i].Key, recording.Key))
+
+				value, err := loaded[i].Value.(*rawValue).decode(recording.Value)
+				require.NoError(t, err)
+				require.Equal(t, recording.Value, value)
+			}
+
+			names, err := storage.List()
+			require.NoError(t, err)
+			require.Equal(t, []string{"TestA/sub case"}, names)
+
+			// loaded recordings are rendered as they were loaded
+			diff, err := storage.diff("TestA/sub case", loaded)
+			require.NoError(t, err)
+			require.Empty(t, diff)
+
+			require.NoError(t, storage.Save("TestA/sub case", nil))
+			require.NoFileExists(t, filepath.Join(storage.dir, "TestA", "sub case."+format.String()))
+		})
+	}
+
+	t.Run("json file", func(t *testing.T) {
+		storage := NewGoldenStorage(t.TempDir(), FormatJSON)
+		require.NoError(t, storage.Save("TestA", []Recording{{0, "value"}}))
+
+		data, err := ioutil.ReadFile(filepath.Join(storage.dir, "TestA.json"))
+		require.NoError(t, err)
+		require.Equal(t, `{
+  "test": "TestA",
+  "recordings": [
+    {
+      "key": 0,
+      "keyType": "int",
+      "type": "string",
+      "value": "value"
+    }
+  ]
+}
+`, string(data))
+	})
+
+	t.Run("missing file", func(t *testing.T) {
+		storage := NewGoldenStorage(t.TempDir(), FormatJSON)
+
+		loaded, err := storage.Load("TestA")
+		require.NoError(t, err)
+		require.Nil(t, loaded)
+	})
+}
+
+func TestRawValueDecode(t *testing.T) {
+	raw := &rawValue{"testparrot.testStruct", []byte(`{"V1": "value"}`)}
+
+	value, err := raw.decode(testStruct{})
+	require.NoError(t, err)
+	require.Equal(t, testStruct{V1: "value"}, value)
+
+	// without value of recorded type, value is decoded as generic JSON
+	value, err = raw.decode(nil)
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{"V1": "value"}, value)
+
+	// basic types are decoded without value
+	value, err = (&rawValue{"int64", []byte("5")}).decode(nil)
+	require.NoError(t, err)
+	require.Equal(t, int64(5), value)
+}
+
+func TestGoldenStorageLosses(t *testing.T) {
+	type account struct {
+		Name    string
+		Values  map[string]interface{}
+		Created time.Time
+		note    string
+	}
+
+	for _, format := range []Format{FormatJSON, FormatYAML} {
+		t.Run(format.String(), func(t *testing.T) {
+			storage := NewGoldenStorage(t.TempDir(), format)
+
+			require.Empty(t, storage.Losses(account{
+				Name:    "name",
+				Values:  map[string]interface{}{"a": "b", "c": []interface{}{true}},
+				Created: time.Date(1999, 1, 2, 3, 4, 5, 0, time.UTC),
+			}))
+
+			require.Equal(t, []Loss{
+				{`.Values["count"]`, "1 is replayed with another type"},
+				{".note", `recorded "note", replayed ""`},
+			}, storage.Losses(account{Values: map[string]interface{}{"count": 1}, note: "note"}))
+
+			require.Equal(t, []Loss{
+				{"", "cannot be stored as " + strings.ToUpper(format.String()) + ": json: unsupported value: NaN"},
+			}, storage.Losses(math.NaN()))
+		})
+	}
+}
+
+func TestRecorderGoldenLossy(t *testing.T) {
+	recorder := NewRecorder()
+	recorder.SetStorage(NewGoldenStorage(t.TempDir(), FormatJSON))
+	recorder.EnableRecording(true)
+
+	ft := &fakeTB{TB: t}
+	require.Nil(t, recorder.Record(ft, "key", []interface{}{int64(1)}))
+	require.Equal(t, []string{
+		"testparrot: value with key 'key' for test '" + t.Name() + "' cannot be recorded without losing data:\n" +
+			"\t[0]: 1 is replayed with another type",
+	}, ft.failures)
+
+	// values that round trip through JSON are recorded
+	ft = &fakeTB{TB: t}
+	require.Equal(t, testStruct{V1: "value"}, recorder.Record(ft, "other", testStruct{V1: "value"}))
+	require.Empty(t, ft.failures)
+}
+
+func TestEscapeFilename(t *testing.T) {
+	require.Equal(t, "a%3Ab%25c", escapeFilename("a:b%c"))
+	require.Equal(t, "a:b%c", unescapeFilename("a%3Ab%25c"))
+}
+
+func TestRecorderGolden(t *testing.T) {
+	dir := t.TempDir()
+	storage := NewGoldenStorage(dir, FormatJSON)
+	require.NoError(t, storage.Save(t.Name(), []Recording{
+		{"key", testStruct{V1: "value"}},
+		{0, 10},
+	}))
+
+	recorder := NewRecorder()
+	recorder.SetStorage(storage)
+
+	// golden file is loaded when test first uses recorder
+	require.NotContains(t, recorder.allRecordings, t.Name())
+	require.Equal(t, testStruct{V1: "value"}, recorder.Record(t, "key", testStruct{}))
+	require.Equal(t, 10, recorder.RecordNext(t, nil))
+	require.Equal(t, map[string]bool{t.Name(): true}, recorder.storageFiles())
+
+	// recordings of tests that did not run are loaded too
+	require.NoError(t, storage.Save("TestOther", []Recording{{0, "value"}}))
+	require.NoError(t, recorder.loadAllStorage())
+	require.Contains(t, recorder.allRecordings, "TestOther")
+}
@@ -0,0 +1,534 @@
+package testparrot
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"path"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"unicode"
+
+	. "github.com/dave/jennifer/jen"
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+// inlineSnapshot defines a value that needs to be written as expected value
+// of ExpectInline call in test source
+type inlineSnapshot struct {
+	// filename defines path of test file
+	filename string
+
+	// line defines line of ExpectInline call
+	line int
+
+	// pkgPath defines path of test package
+	pkgPath string
+
+	// test defines name of test that recorded value
+	test string
+
+	// value defines actual value
+	value interface{}
+
+	// constructors defines constructors of types with unexported fields,
+	// registered on recorder that recorded value
+	constructors map[reflect.Type]constructor
+}
+
+// ExpectInline method compares actual value with expected value, which is
+// kept in test source. When recording is enabled and expected value is
+// missing or differs, expected argument of ExpectInline call is rewritten in
+// test source after tests finish. Otherwise test fails with a list of
+// differences between values. ExpectInline returns whether values are equal.
+func (r *Recorder) ExpectInline(t testing.TB, actual interface{}, expected ...interface{}) bool {
+	t.Helper()
+
+	if len(expected) > 1 {
+		r.fail(t, newErr(fmt.Errorf("ExpectInline accepts a single expected value, got %d", len(expected))))
+		return false
+	}
+
+	var diffs []Difference
+	if len(expected) == 1 {
+		var err error
+		if diffs, err = r.Compare(expected[0], actual); err != nil {
+			r.fail(t, err)
+			return false
+		}
+
+		if len(diffs) == 0 {
+			return true
+		}
+	}
+
+	mode := r.Mode()
+
+	// in missing mode only missing snapshots are recorded
+	if mode == ModeRecord || (mode == ModeMissing && len(expected) == 0) {
+		filename, line, pkgPath, err := getCallSite()
+		if err != nil {
+			r.fail(t, newErr(err))
+			return false
+		}
+
+		value, err := r.clean(actual)
+		if err != nil {
+			r.fail(t, err)
+			return false
+		}
+
+		constructors := r.getConstructors()
+
+		g := &Generator{pkgPath: pkgPath, constructors: constructors}
+		if losses := g.Losses(value); len(losses) > 0 {
+			r.fail(t, newErr(fmt.Errorf("inline snapshot for test '%s' cannot be recorded without losing data:\n%s",
+				t.Name(), formatLosses(losses))))
+			return false
+		}
+
+		if err := r.addSnapshot(inlineSnapshot{filename, line, pkgPath, t.Name(), value, constructors}); err != nil {
+			r.fail(t, err)
+			return false
+		}
+
+		return true
+	}
+
+	if len(expected) == 0 {
+		t.Errorf("%v", newErr(fmt.Errorf(
+			"missing inline snapshot for test '%s', record it with -testparrot.record", t.Name())))
+		return false
+	}
+
+	t.Errorf("%v", newErr(fmt.Errorf(
+		"value differs from inline snapshot for test '%s':\n%s", t.Name(), formatDiff(diffs))))
+
+	return false
+}
+
+// addSnapshot adds recorded inline snapshot. Expected value of a call site
+// can only be a single value, so snapshot fails if call site, like a call in
+// a table driven test or a loop, already recorded a different value.
+func (r *Recorder) addSnapshot(snapshot inlineSnapshot) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, other := range r.inlineSnapshots {
+		if other.filename != snapshot.filename || other.line != snapshot.line {
+			continue
+		}
+
+		diffs, err := Diff(other.value, snapshot.value)
+		if err != nil {
+			return err
+		}
+
+		if len(diffs) == 0 {
+			return nil
+		}
+
+		return newErr(fmt.Errorf(
+			"ExpectInline call at %s:%d recorded different values in test '%s' and test '%s', "+
+				"but inline snapshot holds a single value, use Expect for values that differ "+
+				"between calls and put every ExpectInline call on its own line:\n%s",
+			path.Base(snapshot.filename), snapshot.line, other.test, snapshot.test, formatDiff(diffs)))
+	}
+
+	r.inlineSnapshots = append(r.inlineSnapshots, snapshot)
+
+	return nil
+}
+
+// snapshots returns a copy of recorded inline snapshots
+func (r *Recorder) snapshots() []inlineSnapshot {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]inlineSnapshot(nil), r.inlineSnapshots...)
+}
+
+// rewriteInlineSnapshots writes inline snapshots into test sources. If write
+// is false, sources are not written and diffs of changed sources are
+// returned instead.
+func rewriteInlineSnapshots(snapshots []inlineSnapshot, write bool) ([]string, error) {
+	byFilename := map[string][]inlineSnapshot{}
+	for _, snapshot := range snapshots {
+		byFilename[snapshot.filename] = append(byFilename[snapshot.filename], snapshot)
+	}
+
+	filenames := make([]string, 0, len(byFilename))
+	for filename := range byFilename {
+		filenames = append(filenames, filename)
+	}
+	sort.Strings(filenames)
+
+	diffs := []string{}
+	for _, filename := range filenames {
+		src, err := ioutil.ReadFile(filename)
+		if err != nil {
+			return nil, err
+		}
+
+		newSrc, err := rewriteInline(filename, src, byFilename[filename])
+		if err != nil {
+			return nil, err
+		}
+
+		if bytes.Equal(src, newSrc) {
+			continue
+		}
+
+		if write {
+			if err := ioutil.WriteFile(filename, newSrc, 0660); err != nil {
+				return nil, err
+			}
+
+			continue
+		}
+
+		diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+			A:        difflib.SplitLines(string(src)),
+			B:        difflib.SplitLines(string(newSrc)),
+			FromFile: filename,
+			ToFile:   filename + " (recorded)",
+			Context:  3,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		diffs = append(diffs, diff)
+	}
+
+	return diffs, nil
+}
+
+// sourceEdit defines replacement of a range of source
+type sourceEdit struct {
+	start int
+	end   int
+	text  string
+}
+
+// applyEdits applies non overlapping edits to source
+func applyEdits(src []byte, edits []sourceEdit) []byte {
+	sort.Slice(edits, func(i, j int) bool {
+		return edits[i].start > edits[j].start
+	})
+
+	result := append([]byte(nil), src...)
+	for _, edit := range edits {
+		result = append(result[:edit.start], append([]byte(edit.text), result[edit.end:]...)...)
+	}
+
+	return result
+}
+
+// rewriteInline replaces expected arguments of ExpectInline calls in source
+// of a test file with values of snapshots
+func rewriteInline(filename string, src []byte, snapshots []inlineSnapshot) ([]byte, error) {
+	fset := token.NewFileSet()
+	astFile, err := parser.ParseFile(fset, filename, src, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+
+	offset := func(pos token.Pos) int {
+		return fset.Position(pos).Offset
+	}
+
+	// local names of imported packages by import path
+	localNames := map[string]string{}
+	for _, spec := range astFile.Imports {
+		importPath, _ := strconv.Unquote(spec.Path.Value)
+		if spec.Name != nil {
+			localNames[importPath] = spec.Name.Name
+		} else {
+			localNames[importPath] = guessPkgName(importPath)
+		}
+	}
+
+	// call sites record equal values, like when running with -count, so
+	// first snapshot of every call site is used
+	byLine := map[int]inlineSnapshot{}
+	for _, snapshot := range snapshots {
+		if _, ok := byLine[snapshot.line]; !ok {
+			byLine[snapshot.line] = snapshot
+		}
+	}
+
+	edits := []sourceEdit{}
+	missingImports := map[string]string{}
+
+	for line, snapshot := range byLine {
+		calls := findInlineCalls(fset, astFile, line)
+		switch {
+		case len(calls) == 0:
+			return nil, fmt.Errorf("%s:%d: ExpectInline call not found", filename, line)
+		case len(calls) > 1:
+			return nil, fmt.Errorf("%s:%d: multiple ExpectInline calls on a line, put every call on its own line", filename, line)
+		}
+
+		call := calls[0]
+
+		expr, imports, err := inlineValueCode(snapshot.pkgPath, astFile.Name.Name, snapshot.value, snapshot.constructors)
+		if err != nil {
+			return nil, err
+		}
+
+		// rename packages referenced by value to names used in test file
+		renames := map[string]string{}
+		for importPath, alias := range imports {
+			if name, ok := localNames[importPath]; ok {
+				renames[alias] = name
+			} else {
+				missingImports[importPath] = alias
+			}
+		}
+
+		expr, err = renamePackages(expr, renames)
+		if err != nil {
+			return nil, err
+		}
+
+		edits = append(edits, sourceEdit{
+			start: offset(call.Args[1].End()),
+			end:   offset(call.Rparen),
+			text:  ", " + expr,
+		})
+	}
+
+	if len(missingImports) > 0 {
+		edits = append(edits, importEdits(fset, astFile, missingImports)...)
+	}
+
+	return format.Source(applyEdits(src, edits))
+}
+
+// findInlineCalls finds ExpectInline calls which span line. Calls are
+// identified by lines of their callers, so nested calls are not searched.
+func findInlineCalls(fset *token.FileSet, astFile *ast.File, line int) []*ast.CallExpr {
+	var found []*ast.CallExpr
+
+	ast.Inspect(astFile, func(node ast.Node) bool {
+		call, ok := node.(*ast.CallExpr)
+		if !ok {
+			return true
+		}
+
+		var name string
+		switch fun := call.Fun.(type) {
+		case *ast.SelectorExpr:
+			name = fun.Sel.Name
+		case *ast.Ident:
+			name = fun.Name
+		}
+
+		if name == "ExpectInline" && len(call.Args) >= 2 &&
+			fset.Position(call.Pos()).Line <= line && line <= fset.Position(call.End()).Line {
+			found = append(found, call)
+			return false
+		}
+
+		return true
+	})
+
+	return found
+}
+
+// importEdits adds missing imports to test file. Standard library imports
+// are added to group of standard library imports and other imports to group
+// of other imports, like goimports does.
+func importEdits(fset *token.FileSet, astFile *ast.File, imports map[string]string) []sourceEdit {
+	paths := make([]string, 0, len(imports))
+	for importPath := range imports {
+		paths = append(paths, importPath)
+	}
+	sort.Strings(paths)
+
+	stdSpecs, otherSpecs := []string{}, []string{}
+	for _, importPath := range paths {
+		spec := strconv.Quote(importPath)
+		if imports[importPath] != guessPkgName(importPath) {
+			spec = imports[importPath] + " " + spec
+		}
+
+		if isStdImport(importPath) {
+			stdSpecs = append(stdSpecs, spec)
+		} else {
+			otherSpecs = append(otherSpecs, spec)
+		}
+	}
+
+	offset := func(pos token.Pos) int {
+		return fset.Position(pos).Offset
+	}
+
+	// add imports to existing import block, after last import of the same
+	// group, or create new import declaration after package clause
+	for _, decl := range astFile.Decls {
+		genDecl, ok := decl.(*ast.GenDecl)
+		if !ok || genDecl.Tok != token.IMPORT || !genDecl.Rparen.IsValid() {
+			continue
+		}
+
+		var lastStd, lastOther token.Pos
+		for _, spec := range genDecl.Specs {
+			importPath, _ := strconv.Unquote(spec.(*ast.ImportSpec).Path.Value)
+			if isStdImport(importPath) {
+				lastStd = spec.End()
+			} else {
+				lastOther = spec.End()
+			}
+		}
+
+		edits := []sourceEdit{}
+		switch {
+		case len(stdSpecs) == 0:
+		case lastStd.IsValid():
+			edits = append(edits, sourceEdit{offset(lastStd), offset(lastStd), "\n\t" + strings.Join(stdSpecs, "\n\t")})
+		default:
+			start := offset(genDecl.Lparen) + 1
+			edits = append(edits, sourceEdit{start, start, "\n\t" + strings.Join(stdSpecs, "\n\t") + "\n"})
+		}
+
+		switch {
+		case len(otherSpecs) == 0:
+		case lastOther.IsValid():
+			edits = append(edits, sourceEdit{offset(lastOther), offset(lastOther), "\n\t" + strings.Join(otherSpecs, "\n\t")})
+		default:
+			end := offset(genDecl.Rparen)
+			edits = append(edits, sourceEdit{end, end, "\n\t" + strings.Join(otherSpecs, "\n\t") + "\n"})
+		}
+
+		return edits
+	}
+
+	groups := []string{}
+	for _, specs := range [][]string{stdSpecs, otherSpecs} {
+		if len(specs) > 0 {
+			groups = append(groups, "\t"+strings.Join(specs, "\n\t")+"\n")
+		}
+	}
+
+	end := offset(astFile.Name.End())
+	return []sourceEdit{{end, end, "\n\nimport (\n" + strings.Join(groups, "\n") + ")"}}
+}
+
+// isStdImport returns whether import path is a path of standard library
+// package, whose first element has no dots
+func isStdImport(importPath string) bool {
+	return !strings.Contains(strings.Split(importPath, "/")[0], ".")
+}
+
+// inlineValueCode generates code of a value, using registered constructors,
+// and returns it together with imports it needs, as aliases by import path
+func inlineValueCode(pkgPath string, pkgName string, value interface{}, constructors map[reflect.Type]constructor) (string, map[string]string, error) {
+	g := NewGenerator(pkgPath, pkgName)
+	g.constructors = constructors
+
+	code, err := valToCode(g, reflect.ValueOf(value), reflect.Value{})
+	if err != nil {
+		return "", nil, err
+	}
+
+	// render value in a file, so imports it needs are known
+	f := NewFilePathName(pkgPath, pkgName)
+	f.Var().Id("_").Op("=").Add(code)
+
+	buf := &bytes.Buffer{}
+	if err := f.Render(buf); err != nil {
+		return "", nil, err
+	}
+
+	fset := token.NewFileSet()
+	astFile, err := parser.ParseFile(fset, "", buf.Bytes(), 0)
+	if err != nil {
+		return "", nil, err
+	}
+
+	imports := map[string]string{}
+	for _, spec := range astFile.Imports {
+		importPath, _ := strconv.Unquote(spec.Path.Value)
+		if spec.Name != nil {
+			imports[importPath] = spec.Name.Name
+		} else {
+			imports[importPath] = guessPkgName(importPath)
+		}
+	}
+
+	for _, decl := range astFile.Decls {
+		genDecl, ok := decl.(*ast.GenDecl)
+		if !ok || genDecl.Tok != token.VAR {
+			continue
+		}
+
+		valueExpr := genDecl.Specs[0].(*ast.ValueSpec).Values[0]
+		start := fset.Position(valueExpr.Pos()).Offset
+		end := fset.Position(valueExpr.End()).Offset
+
+		return string(buf.Bytes()[start:end]), imports, nil
+	}
+
+	return "", nil, fmt.Errorf("generated value not found")
+}
+
+// renamePackages renames package references in an expression
+func renamePackages(expr string, renames map[string]string) (string, error) {
+	astExpr, err := parser.ParseExpr(expr)
+	if err != nil {
+		return "", err
+	}
+
+	edits := []sourceEdit{}
+	ast.Inspect(astExpr, func(node ast.Node) bool {
+		sel, ok := node.(*ast.SelectorExpr)
+		if !ok {
+			return true
+		}
+
+		if ident, ok := sel.X.(*ast.Ident); ok {
+			if name, ok := renames[ident.Name]; ok && name != ident.Name {
+				// positions of parsed expression start at 1
+				edits = append(edits, sourceEdit{int(ident.Pos()) - 1, int(ident.End()) - 1, name})
+			}
+		}
+
+		return true
+	})
+
+	return string(applyEdits([]byte(expr), edits)), nil
+}
+
+// guessPkgName guesses name of a package from its import path
+func guessPkgName(importPath string) string {
+	if importPath == pkgPath {
+		return pkgName
+	}
+
+	name := path.Base(importPath)
+
+	// skip major version suffixes, like example.com/pkg/v2
+	if len(name) > 1 && name[0] == 'v' && strings.Trim(name[1:], "0123456789") == "" {
+		name = path.Base(path.Dir(importPath))
+	}
+
+	name = strings.TrimPrefix(name, "go-")
+	name = strings.TrimSuffix(name, "-go")
+
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' {
+			return r
+		}
+
+		return -1
+	}, name)
+}
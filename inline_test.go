@@ -0,0 +1,188 @@
+package testparrot
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecorderExpectInline(t *testing.T) {
+	t.Run("replay equal values", func(t *testing.T) {
+		recorder := NewRecorder()
+		require.True(t, recorder.ExpectInline(t, testStruct{V1: "value"}, testStruct{V1: "value"}))
+	})
+
+	t.Run("replay different values", func(t *testing.T) {
+		recorder := NewRecorder()
+
+		ft := &fakeTB{TB: t}
+		require.False(t, recorder.ExpectInline(ft, "value2", "value1"))
+		require.Equal(t, []string{
+			"testparrot: value differs from inline snapshot for test '" + t.Name() + "':\n" +
+				"\t.: recorded \"value1\", actual \"value2\"",
+		}, ft.failures)
+	})
+
+	t.Run("replay missing snapshot", func(t *testing.T) {
+		recorder := NewRecorder()
+
+		ft := &fakeTB{TB: t}
+		require.False(t, recorder.ExpectInline(ft, "value"))
+		require.Equal(t, []string{
+			"testparrot: missing inline snapshot for test '" + t.Name() + "', record it with -testparrot.record",
+		}, ft.failures)
+	})
+
+	t.Run("recording enabled", func(t *testing.T) {
+		recorder := NewRecorder()
+		recorder.EnableRecording(true)
+
+		require.True(t, recorder.ExpectInline(t, "value2", "value1"))
+		require.Len(t, recorder.snapshots(), 1)
+
+		snapshot := recorder.snapshots()[0]
+		require.Equal(t, "value2", snapshot.value)
+		require.Equal(t, pkgPath, snapshot.pkgPath)
+		require.Contains(t, snapshot.filename, "inline_test.go")
+	})
+
+	t.Run("call site records different values", func(t *testing.T) {
+		recorder := NewRecorder()
+		recorder.EnableRecording(true)
+
+		ft := &fakeTB{TB: t}
+		for _, value := range []string{"value1", "value1", "value2"} {
+			recorder.ExpectInline(ft, value)
+		}
+
+		require.Len(t, recorder.snapshots(), 1)
+		require.Len(t, ft.failures, 1)
+		require.Contains(t, ft.failures[0], "testparrot: ExpectInline call at inline_test.go:")
+		require.Contains(t, ft.failures[0], "recorded different values in test '"+t.Name()+"' and test '"+t.Name()+"'")
+		require.Contains(t, ft.failures[0], "\t.: recorded \"value1\", actual \"value2\"")
+	})
+
+	t.Run("missing mode records only missing snapshots", func(t *testing.T) {
+		recorder := NewRecorder()
+		recorder.SetMode(ModeMissing)
+
+		ft := &fakeTB{TB: t}
+		require.False(t, recorder.ExpectInline(ft, "value2", "value1"))
+		require.True(t, recorder.ExpectInline(ft, "value"))
+		require.Len(t, recorder.snapshots(), 1)
+	})
+}
+
+func TestRewriteInline(t *testing.T) {
+	src := `package example
+
+import (
+	"testing"
+
+	tp "github.com/xtruder/go-testparrot"
+)
+
+func TestSomething(t *testing.T) {
+	tp.ExpectInline(t, getValue())
+	tp.ExpectInline(t, getOther(),
+		"old",
+	)
+	tp.ExpectInline(t, getDuration(), 0)
+}
+`
+
+	newSrc, err := rewriteInline("example_test.go", []byte(src), []inlineSnapshot{
+		{line: 10, pkgPath: "example.com/example", value: &SeqKey{Seq: "seq", Index: 1}},
+		{line: 11, pkgPath: "example.com/example", value: "new"},
+		{line: 14, pkgPath: "example.com/example", value: time.Second},
+	})
+	require.NoError(t, err)
+	require.Equal(t, `package example
+
+import (
+	"testing"
+	"time"
+
+	tp "github.com/xtruder/go-testparrot"
+)
+
+func TestSomething(t *testing.T) {
+	tp.ExpectInline(t, getValue(), &tp.SeqKey{
+		Index: 1,
+		Seq:   "seq",
+	})
+	tp.ExpectInline(t, getOther(), "new")
+	tp.ExpectInline(t, getDuration(), time.Duration(int64(1000000000)))
+}
+`, string(newSrc))
+}
+
+func TestRewriteInlineImports(t *testing.T) {
+	tests := []struct {
+		name     string
+		imports  string
+		expected string
+	}{
+		{
+			name:     "standard library imports",
+			imports:  "import (\n\t\"testing\"\n)\n",
+			expected: "import (\n\t\"testing\"\n\t\"time\"\n\n\tgotestparrot \"github.com/xtruder/go-testparrot\"\n)\n",
+		},
+		{
+			name:     "other imports",
+			imports:  "import (\n\t\"github.com/google/uuid\"\n)\n",
+			expected: "import (\n\t\"time\"\n\n\t\"github.com/google/uuid\"\n\tgotestparrot \"github.com/xtruder/go-testparrot\"\n)\n",
+		},
+		{
+			name:     "single import",
+			imports:  "import \"testing\"\n",
+			expected: "import (\n\t\"time\"\n\n\tgotestparrot \"github.com/xtruder/go-testparrot\"\n)\n\nimport \"testing\"\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			src := "package example\n\n" + test.imports + "\nfunc TestSomething(t *testing.T) {\n\tExpectInline(t, getValue())\n}\n"
+
+			newSrc, err := rewriteInline("example_test.go", []byte(src), []inlineSnapshot{
+				{line: 5 + strings.Count(test.imports, "\n"), pkgPath: "example.com/example", value: []interface{}{
+					time.Second, SeqKey{},
+				}},
+			})
+			require.NoError(t, err)
+			require.Equal(t, "package example\n\n"+test.expected, string(newSrc[:len(test.expected)+len("package example\n\n")]))
+		})
+	}
+}
+
+func TestRewriteInlineMultipleCalls(t *testing.T) {
+	src := `package example
+
+func TestSomething(t *testing.T) {
+	ExpectInline(t, getValue()); ExpectInline(t, getOther())
+}
+`
+
+	_, err := rewriteInline("example_test.go", []byte(src), []inlineSnapshot{{line: 4, value: "value"}})
+	require.EqualError(t, err, "example_test.go:4: multiple ExpectInline calls on a line, put every call on its own line")
+}
+
+func TestRewriteInlineConstructor(t *testing.T) {
+	src := `package testparrot
+
+func TestSomething(t *testing.T) {
+	ExpectInline(t, getMoney())
+}
+`
+
+	recorder := NewRecorder()
+	recorder.RegisterConstructor(newTestMoney, testMoneyArgs)
+
+	newSrc, err := rewriteInline("example_test.go", []byte(src), []inlineSnapshot{
+		{line: 4, pkgPath: pkgPath, value: newTestMoney(100, "EUR"), constructors: recorder.getConstructors()},
+	})
+	require.NoError(t, err)
+	require.Contains(t, string(newSrc), `ExpectInline(t, getMoney(), newTestMoney(int64(100), "EUR"))`)
+}
@@ -0,0 +1,148 @@
+package recfile
+
+// ChangeKind defines kind of change of a recording
+type ChangeKind int
+
+const (
+	// Added defines recording that is new
+	Added ChangeKind = iota
+
+	// Changed defines recording with changed value
+	Changed
+
+	// Removed defines recording that is no longer recorded
+	Removed
+)
+
+func (k ChangeKind) String() string {
+	switch k {
+	case Added:
+		return "added"
+	case Changed:
+		return "changed"
+	default:
+		return "removed"
+	}
+}
+
+// Change defines a change of a single recording between two files
+type Change struct {
+	// Test defines name of a test
+	Test string
+
+	// Kind defines kind of change
+	Kind ChangeKind
+
+	// Key defines source of recording key
+	Key string
+
+	// Old defines source of old value, empty if recording was added
+	Old string
+
+	// New defines source of new value, empty if recording was removed
+	New string
+}
+
+// Changes returns changes of recordings between old and new file. Old file
+// can be nil, when there is no file yet.
+func Changes(old *File, new *File) []Change {
+	if old == nil {
+		old = &File{}
+	}
+
+	changes := []Change{}
+	for _, newTest := range new.Tests {
+		oldTest := old.Test(newTest.Name)
+		if oldTest == nil {
+			oldTest = &Test{Name: newTest.Name}
+		}
+
+		changes = append(changes, testChanges(oldTest, newTest)...)
+	}
+
+	for _, oldTest := range old.Tests {
+		if new.Test(oldTest.Name) == nil {
+			changes = append(changes, testChanges(oldTest, &Test{Name: oldTest.Name})...)
+		}
+	}
+
+	return changes
+}
+
+func testChanges(old *Test, new *Test) []Change {
+	changes := []Change{}
+
+	for _, recording := range new.Recordings {
+		oldRecording := old.Recording(recording.Key)
+
+		switch {
+		case oldRecording == nil:
+			changes = append(changes, Change{
+				Test: new.Name, Kind: Added, Key: recording.Key, New: recording.Value,
+			})
+		case oldRecording.Value != recording.Value:
+			changes = append(changes, Change{
+				Test: new.Name, Kind: Changed, Key: recording.Key, Old: oldRecording.Value, New: recording.Value,
+			})
+		}
+	}
+
+	for _, recording := range old.Recordings {
+		if new.Recording(recording.Key) == nil {
+			changes = append(changes, Change{
+				Test: old.Name, Kind: Removed, Key: recording.Key, Old: recording.Value,
+			})
+		}
+	}
+
+	return changes
+}
+
+// Revert returns a copy of new file with rejected changes reverted to
+// values from old file
+func Revert(old *File, new *File, rejected []Change) *File {
+	result := new.clone()
+	if old != nil {
+		result.MergeImports(old)
+	}
+
+	for _, change := range rejected {
+		test := result.Test(change.Test)
+		if test == nil {
+			test = &Test{Name: change.Test}
+			result.Tests = append(result.Tests, test)
+		}
+
+		switch change.Kind {
+		case Added:
+			for i, recording := range test.Recordings {
+				if recording.Key == change.Key {
+					test.Recordings = append(test.Recordings[:i], test.Recordings[i+1:]...)
+					break
+				}
+			}
+		case Changed:
+			test.Recording(change.Key).Value = change.Old
+		case Removed:
+			test.Recordings = append(test.Recordings, Recording{Key: change.Key, Value: change.Old})
+		}
+	}
+
+	return result
+}
+
+// clone returns a deep copy of file
+func (f *File) clone() *File {
+	result := *f
+	result.Imports = append([]Import(nil), f.Imports...)
+	result.Tests = make([]*Test, 0, len(f.Tests))
+
+	for _, test := range f.Tests {
+		result.Tests = append(result.Tests, &Test{
+			Name:       test.Name,
+			Recordings: append([]Recording(nil), test.Recordings...),
+		})
+	}
+
+	return &result
+}
@@ -0,0 +1,56 @@
+package recfile
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestChanges(t *testing.T) {
+	old := &File{Tests: []*Test{
+		{Name: "TestA", Recordings: []Recording{{"0", "1"}, {"1", "2"}}},
+		{Name: "TestB", Recordings: []Recording{{"0", "1"}}},
+	}}
+
+	new := &File{Tests: []*Test{
+		{Name: "TestA", Recordings: []Recording{{"0", "1"}, {"1", "3"}, {"2", "4"}}},
+		{Name: "TestC", Recordings: []Recording{{"0", "5"}}},
+	}}
+
+	require.Equal(t, []Change{
+		{Test: "TestA", Kind: Changed, Key: "1", Old: "2", New: "3"},
+		{Test: "TestA", Kind: Added, Key: "2", New: "4"},
+		{Test: "TestC", Kind: Added, Key: "0", New: "5"},
+		{Test: "TestB", Kind: Removed, Key: "0", Old: "1"},
+	}, Changes(old, new))
+
+	require.Equal(t, []Change{
+		{Test: "TestA", Kind: Added, Key: "0", New: "1"},
+	}, Changes(nil, &File{Tests: []*Test{{Name: "TestA", Recordings: []Recording{{"0", "1"}}}}}))
+}
+
+func TestRevert(t *testing.T) {
+	old := &File{
+		Imports: []Import{{Path: "time"}},
+		Tests: []*Test{
+			{Name: "TestA", Recordings: []Recording{{"0", "1"}, {"1", "2"}}},
+			{Name: "TestB", Recordings: []Recording{{"0", "1"}}},
+		},
+	}
+
+	new := &File{Tests: []*Test{
+		{Name: "TestA", Recordings: []Recording{{"0", "1"}, {"1", "3"}, {"2", "4"}}},
+	}}
+
+	changes := Changes(old, new)
+	result := Revert(old, new, []Change{changes[0], changes[1], changes[2]})
+
+	require.Equal(t, []Import{{Path: "time"}}, result.Imports)
+	require.Equal(t, []*Test{
+		{Name: "TestA", Recordings: []Recording{{"0", "1"}, {"1", "2"}}},
+		{Name: "TestB", Recordings: []Recording{{"0", "1"}}},
+	}, result.Tests)
+
+	// new file is not modified
+	require.Len(t, new.Tests[0].Recordings, 3)
+}
@@ -0,0 +1,376 @@
+// Package recfile parses and renders generated recording files at source
+// level, so recordings can be inspected and edited without running tests.
+package recfile
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const headerComment = "// Code generated by testparrot. DO NOT EDIT."
+
+// Import defines an import of a recording file
+type Import struct {
+	// Name defines explicit import name, empty if there is none
+	Name string
+
+	// Path defines import path
+	Path string
+}
+
+// localName returns name under which import is referenced in file
+func (i Import) localName() string {
+	if i.Name != "" {
+		return i.Name
+	}
+
+	return path.Base(i.Path)
+}
+
+// Recording defines source of a single recording
+type Recording struct {
+	// Key defines source of recording key
+	Key string
+
+	// Value defines source of recording value
+	Value string
+}
+
+// format formats recording as composite literal. Like generator, nil fields
+// are omitted and literal with a single field is kept on a single line.
+func (r Recording) format() string {
+	fields := []string{}
+	if r.Key != "nil" {
+		fields = append(fields, "Key: "+r.Key)
+	}
+	if r.Value != "nil" {
+		fields = append(fields, "Value: "+r.Value)
+	}
+
+	if len(fields) < 2 {
+		return "{" + strings.Join(fields, "") + "}"
+	}
+
+	return "{\n" + strings.Join(fields, ",\n") + ",\n}"
+}
+
+// Test defines recordings of a single test
+type Test struct {
+	// Name defines name of a test
+	Name string
+
+	// Recordings defines recordings of a test
+	Recordings []Recording
+}
+
+// Recording returns recording with key, or nil if there is none
+func (t *Test) Recording(key string) *Recording {
+	for i := range t.Recordings {
+		if t.Recordings[i].Key == key {
+			return &t.Recordings[i]
+		}
+	}
+
+	return nil
+}
+
+// File defines a generated recording file
+type File struct {
+	// Package defines package name of a file
+	Package string
+
+	// Imports defines imports of a file
+	Imports []Import
+
+	// LoadFunc defines source of function that loads recordings
+	LoadFunc string
+
+	// RecordingsType defines source of type of recordings slice
+	RecordingsType string
+
+	// Tests defines recordings of tests, in order of loading
+	Tests []*Test
+}
+
+// Test returns test with name, or nil if there is none
+func (f *File) Test(name string) *Test {
+	for _, test := range f.Tests {
+		if test.Name == name {
+			return test
+		}
+	}
+
+	return nil
+}
+
+// Find returns paths of generated recording files in a directory, sorted by
+// name. Generated files are recognized by their header comment, so files with
+// custom names are found too.
+func Find(dir string) ([]string, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*_test.go"))
+	if err != nil {
+		return nil, err
+	}
+
+	found := []string{}
+	for _, path := range paths {
+		src, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		if bytes.HasPrefix(src, []byte(headerComment)) {
+			found = append(found, path)
+		}
+	}
+
+	sort.Strings(found)
+
+	return found, nil
+}
+
+// ParseFile reads and parses generated recording file
+func ParseFile(filename string) (*File, error) {
+	src, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	return Parse(filename, src)
+}
+
+// Parse parses source of generated recording file
+func Parse(filename string, src []byte) (*File, error) {
+	fset := token.NewFileSet()
+	astFile, err := parser.ParseFile(fset, filename, src, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	text := func(node ast.Node) string {
+		return string(src[fset.Position(node.Pos()).Offset:fset.Position(node.End()).Offset])
+	}
+
+	file := &File{Package: astFile.Name.Name}
+
+	for _, spec := range astFile.Imports {
+		importPath, err := strconv.Unquote(spec.Path.Value)
+		if err != nil {
+			return nil, err
+		}
+
+		imp := Import{Path: importPath}
+		if spec.Name != nil {
+			imp.Name = spec.Name.Name
+		}
+
+		file.Imports = append(file.Imports, imp)
+	}
+
+	for _, decl := range astFile.Decls {
+		funcDecl, ok := decl.(*ast.FuncDecl)
+		if !ok || funcDecl.Name.Name != "init" || funcDecl.Recv != nil {
+			continue
+		}
+
+		for _, stmt := range funcDecl.Body.List {
+			test, err := parseLoad(file, stmt, text)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", fset.Position(stmt.Pos()), err)
+			}
+
+			file.Tests = append(file.Tests, test)
+		}
+	}
+
+	return file, nil
+}
+
+// parseLoad parses a single call that loads recordings of a test
+func parseLoad(file *File, stmt ast.Stmt, text func(ast.Node) string) (*Test, error) {
+	exprStmt, ok := stmt.(*ast.ExprStmt)
+	if !ok {
+		return nil, errors.New("expected call to Load")
+	}
+
+	call, ok := exprStmt.X.(*ast.CallExpr)
+	if !ok || len(call.Args) != 2 {
+		return nil, errors.New("expected call to Load with two arguments")
+	}
+
+	nameLit, ok := call.Args[0].(*ast.BasicLit)
+	if !ok || nameLit.Kind != token.STRING {
+		return nil, errors.New("expected test name as string literal")
+	}
+
+	name, err := strconv.Unquote(nameLit.Value)
+	if err != nil {
+		return nil, err
+	}
+
+	recordings, ok := call.Args[1].(*ast.CompositeLit)
+	if !ok || recordings.Type == nil {
+		return nil, errors.New("expected recordings as composite literal")
+	}
+
+	file.LoadFunc = text(call.Fun)
+	file.RecordingsType = text(recordings.Type)
+
+	test := &Test{Name: name}
+	for _, elt := range recordings.Elts {
+		recordingLit, ok := elt.(*ast.CompositeLit)
+		if !ok {
+			return nil, errors.New("expected recording as composite literal")
+		}
+
+		recording := Recording{}
+		for _, field := range recordingLit.Elts {
+			kv, ok := field.(*ast.KeyValueExpr)
+			if !ok {
+				return nil, errors.New("expected recording fields as key value pairs")
+			}
+
+			switch text(kv.Key) {
+			case "Key":
+				recording.Key = text(kv.Value)
+			case "Value":
+				recording.Value = text(kv.Value)
+			}
+		}
+
+		// zero values are omitted by generator
+		if recording.Key == "" {
+			recording.Key = "nil"
+		}
+		if recording.Value == "" {
+			recording.Value = "nil"
+		}
+
+		test.Recordings = append(test.Recordings, recording)
+	}
+
+	return test, nil
+}
+
+// Format renders file as formatted go source. Tests are sorted by name, like
+// generator sorts them, and imports which are not used are omitted.
+func (f *File) Format() ([]byte, error) {
+	tests := make([]*Test, 0, len(f.Tests))
+	for _, test := range f.Tests {
+		if len(test.Recordings) > 0 {
+			tests = append(tests, test)
+		}
+	}
+
+	sort.SliceStable(tests, func(i, j int) bool {
+		return tests[i].Name < tests[j].Name
+	})
+
+	body := &bytes.Buffer{}
+	for _, test := range tests {
+		fmt.Fprintf(body, "%s(%s, %s{", f.LoadFunc, strconv.Quote(test.Name), f.RecordingsType)
+		for i, recording := range test.Recordings {
+			if i > 0 {
+				body.WriteString(", ")
+			}
+
+			body.WriteString(recording.format())
+		}
+		body.WriteString("})\n")
+	}
+
+	src := &bytes.Buffer{}
+	fmt.Fprintf(src, "%s\n\npackage %s\n\n", headerComment, f.Package)
+
+	imports := []string{}
+	for _, imp := range f.Imports {
+		if !usesImport(body.String(), imp.localName()) {
+			continue
+		}
+
+		if imp.Name != "" {
+			imports = append(imports, imp.Name+" "+strconv.Quote(imp.Path))
+		} else {
+			imports = append(imports, strconv.Quote(imp.Path))
+		}
+	}
+
+	// imports are sorted by path, like generator sorts them
+	sort.Slice(imports, func(i, j int) bool {
+		return importPath(imports[i]) < importPath(imports[j])
+	})
+
+	switch len(imports) {
+	case 0:
+	case 1:
+		fmt.Fprintf(src, "import %s\n", imports[0])
+	default:
+		fmt.Fprintf(src, "import (\n%s\n)\n", strings.Join(imports, "\n"))
+	}
+
+	fmt.Fprintf(src, "\nfunc init() {\n%s}\n", body.String())
+
+	return format.Source(src.Bytes())
+}
+
+// importPath returns quoted path of import spec
+func importPath(spec string) string {
+	return spec[strings.IndexByte(spec, '"'):]
+}
+
+// usesImport checks whether source references package with name. Source is
+// parsed, so references inside of string literals are not counted.
+func usesImport(src string, name string) bool {
+	fset := token.NewFileSet()
+	astFile, err := parser.ParseFile(fset, "", "package p\nfunc f() {\n"+src+"}\n", 0)
+	if err != nil {
+		// keep import, formatting will report invalid source
+		return true
+	}
+
+	used := false
+	ast.Inspect(astFile, func(node ast.Node) bool {
+		if sel, ok := node.(*ast.SelectorExpr); ok {
+			if ident, ok := sel.X.(*ast.Ident); ok && ident.Name == name {
+				used = true
+			}
+		}
+
+		return !used
+	})
+
+	return used
+}
+
+// MergeImports adds imports of other file, which are missing in file
+func (f *File) MergeImports(other *File) {
+	for _, imp := range other.Imports {
+		found := false
+		for _, existing := range f.Imports {
+			if existing.Path == imp.Path {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			f.Imports = append(f.Imports, imp)
+		}
+	}
+
+	if f.LoadFunc == "" {
+		f.LoadFunc = other.LoadFunc
+		f.RecordingsType = other.RecordingsType
+	}
+}
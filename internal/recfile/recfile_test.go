@@ -0,0 +1,97 @@
+package recfile
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const testSrc = `// Code generated by testparrot. DO NOT EDIT.
+
+package example
+
+import (
+	gotestparrot "github.com/xtruder/go-testparrot"
+	"time"
+)
+
+func init() {
+	gotestparrot.R.Load("TestA", []gotestparrot.Recording{{
+		Key:   "key",
+		Value: gotestparrot.Decode("1999-01-02T03:04:05Z", time.Time{}).(time.Time),
+	}, {Key: 1}})
+	gotestparrot.R.Load("TestB", []gotestparrot.Recording{{
+		Key: 0,
+		Value: ` + "`multi\nline`" + `,
+	}})
+}
+`
+
+func TestParse(t *testing.T) {
+	file, err := Parse("test.go", []byte(testSrc))
+	require.NoError(t, err)
+
+	require.Equal(t, "example", file.Package)
+	require.Equal(t, []Import{
+		{Name: "gotestparrot", Path: "github.com/xtruder/go-testparrot"},
+		{Path: "time"},
+	}, file.Imports)
+	require.Equal(t, "gotestparrot.R.Load", file.LoadFunc)
+	require.Equal(t, "[]gotestparrot.Recording", file.RecordingsType)
+	require.Equal(t, []*Test{
+		{Name: "TestA", Recordings: []Recording{
+			{Key: `"key"`, Value: `gotestparrot.Decode("1999-01-02T03:04:05Z", time.Time{}).(time.Time)`},
+			{Key: "1", Value: "nil"},
+		}},
+		{Name: "TestB", Recordings: []Recording{
+			{Key: "0", Value: "`multi\nline`"},
+		}},
+	}, file.Tests)
+}
+
+func TestParseInvalid(t *testing.T) {
+	_, err := Parse("test.go", []byte("package example\n\nfunc init() {\n\tprintln()\n}\n"))
+	require.EqualError(t, err, "test.go:4:2: expected call to Load with two arguments")
+}
+
+func TestFormat(t *testing.T) {
+	file, err := Parse("test.go", []byte(testSrc))
+	require.NoError(t, err)
+
+	t.Run("round trip", func(t *testing.T) {
+		src, err := file.Format()
+		require.NoError(t, err)
+		require.Equal(t, testSrc, string(src))
+	})
+
+	t.Run("unused imports", func(t *testing.T) {
+		file.Tests = file.Tests[1:]
+
+		src, err := file.Format()
+		require.NoError(t, err)
+		require.Contains(t, string(src), "import gotestparrot \"github.com/xtruder/go-testparrot\"\n")
+		require.NotContains(t, string(src), "\"time\"")
+	})
+}
+
+func TestFind(t *testing.T) {
+	dir := t.TempDir()
+
+	for name, src := range map[string]string{
+		"a_recording_test.go": testSrc,
+		"custom_test.go":      testSrc,
+		"a_test.go":           "package example\n",
+		"b_recording.go":      testSrc,
+	} {
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), []byte(src), 0660))
+	}
+
+	paths, err := Find(dir)
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		filepath.Join(dir, "a_recording_test.go"),
+		filepath.Join(dir, "custom_test.go"),
+	}, paths)
+}
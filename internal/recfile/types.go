@@ -0,0 +1,185 @@
+package recfile
+
+import (
+	"compress/gzip"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Type returns type of recording value, as written in source. Type is
+// derived from source of a value, so it is only known for values in form
+// generator writes them, like literals, conversions and calls to Ptr and
+// Decode. Unknown type is returned as empty string.
+func (r Recording) Type() string {
+	expr, err := parser.ParseExpr(r.Value)
+	if err != nil {
+		return ""
+	}
+
+	return exprType(r.Value, expr)
+}
+
+// Size returns size of recording value in bytes, which is size of its
+// source, except for values stored in blob files, whose size is size of
+// their data. Blob files are read relative to package directory dir.
+func (r Recording) Size(dir string) (int, error) {
+	expr, err := parser.ParseExpr(r.Value)
+	if err != nil {
+		return len(r.Value), nil
+	}
+
+	size := len(r.Value)
+	ast.Inspect(expr, func(node ast.Node) bool {
+		blobPath, ok := blobCallPath(node)
+		if !ok || err != nil {
+			return err == nil
+		}
+
+		var blobSize int
+		if blobSize, err = readBlobSize(filepath.Join(dir, filepath.FromSlash(blobPath))); err != nil {
+			return false
+		}
+
+		// blob call is replaced by its data
+		size += blobSize - int(node.End()-node.Pos())
+
+		return false
+	})
+
+	return size, err
+}
+
+// blobCallPath returns path of blob file, if node is a call to Blob
+func blobCallPath(node ast.Node) (string, bool) {
+	call, ok := node.(*ast.CallExpr)
+	if !ok || len(call.Args) != 1 {
+		return "", false
+	}
+
+	sel, ok := call.Fun.(*ast.SelectorExpr)
+	if !ok || sel.Sel.Name != "Blob" {
+		return "", false
+	}
+
+	lit, ok := call.Args[0].(*ast.BasicLit)
+	if !ok || lit.Kind != token.STRING {
+		return "", false
+	}
+
+	blobPath, err := strconv.Unquote(lit.Value)
+	return blobPath, err == nil
+}
+
+// readBlobSize returns size of data in blob file, compressed blob files with
+// .gz suffix are decompressed
+func readBlobSize(filename string) (int, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return 0, fmt.Errorf("reading blob: %v", err)
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if strings.HasSuffix(filename, ".gz") {
+		gr, err := gzip.NewReader(f)
+		if err != nil {
+			return 0, fmt.Errorf("reading blob %s: %v", filename, err)
+		}
+
+		r = gr
+	}
+
+	n, err := io.Copy(ioutil.Discard, r)
+	if err != nil {
+		return 0, fmt.Errorf("reading blob %s: %v", filename, err)
+	}
+
+	return int(n), nil
+}
+
+// exprType returns type of expression parsed from source
+func exprType(src string, expr ast.Expr) string {
+	text := func(node ast.Node) string {
+		// positions of parsed expression start at 1
+		return src[node.Pos()-1 : node.End()-1]
+	}
+
+	switch e := expr.(type) {
+	case *ast.BasicLit:
+		switch e.Kind {
+		case token.INT:
+			return "int"
+		case token.FLOAT:
+			return "float64"
+		case token.IMAG:
+			return "complex128"
+		case token.CHAR:
+			return "rune"
+		default:
+			return "string"
+		}
+	case *ast.Ident:
+		switch e.Name {
+		case "true", "false":
+			return "bool"
+		case "nil":
+			return "nil"
+		}
+	case *ast.CompositeLit:
+		if e.Type != nil {
+			return text(e.Type)
+		}
+	case *ast.UnaryExpr:
+		if e.Op == token.AND {
+			if elemType := exprType(src, e.X); elemType != "" {
+				return "*" + elemType
+			}
+		}
+	case *ast.TypeAssertExpr:
+		// generator asserts type of values returned by Ptr and Decode
+		if e.Type != nil {
+			return text(e.Type)
+		}
+	case *ast.ParenExpr:
+		return exprType(src, e.X)
+	case *ast.CallExpr:
+		return callType(src, e, text)
+	}
+
+	return ""
+}
+
+// callType returns type of value created by a call, which is either a
+// conversion, or a call to one of testparrot helpers
+func callType(src string, call *ast.CallExpr, text func(ast.Node) string) string {
+	name := text(call.Fun)
+	if sel, ok := call.Fun.(*ast.SelectorExpr); ok {
+		name = sel.Sel.Name
+	}
+
+	switch {
+	case name == "Ptr" && len(call.Args) == 1:
+		if elemType := exprType(src, call.Args[0]); elemType != "" {
+			return "*" + elemType
+		}
+
+		return ""
+	case name == "Decode" && len(call.Args) == 2:
+		return exprType(src, call.Args[1])
+	case name == "Blob" && len(call.Args) == 1:
+		return "[]byte"
+	case len(call.Args) != 1:
+		return ""
+	}
+
+	// everything else is assumed to be a conversion, like time.Duration(1)
+	return text(call.Fun)
+}
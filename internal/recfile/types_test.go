@@ -0,0 +1,78 @@
+package recfile
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordingType(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected string
+	}{
+		{`"value"`, "string"},
+		{"`multi\nline`", "string"},
+		{"10", "int"},
+		{"1.5", "float64"},
+		{"true", "bool"},
+		{"nil", "nil"},
+		{"int64(10)", "int64"},
+		{"time.Duration(10)", "time.Duration"},
+		{"[]string{\"a\"}", "[]string"},
+		{"map[string]int{\"a\": 1}", "map[string]int"},
+		{"&example.Dog{Name: \"Rex\"}", "*example.Dog"},
+		{"gotestparrot.Ptr(\"value\").(*string)", "*string"},
+		{"gotestparrot.Ptr(\"value\")", "*string"},
+		{"gotestparrot.Decode(\"1999-01-02T03:04:05Z\", time.Time{}).(time.Time)", "time.Time"},
+		{"gotestparrot.Decode(\"1999-01-02T03:04:05Z\", time.Time{})", "time.Time"},
+		{"gotestparrot.Blob(\"testdata/testparrot/blobs/abc\")", "[]byte"},
+		{"string(gotestparrot.Blob(\"testdata/testparrot/blobs/abc\"))", "string"},
+		{"getValue(1, 2)", ""},
+		{"invalid(", ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.value, func(t *testing.T) {
+			require.Equal(t, test.expected, Recording{Value: test.value}.Type())
+		})
+	}
+}
+
+func TestRecordingSize(t *testing.T) {
+	dir := t.TempDir()
+	blobDir := filepath.Join(dir, "testdata", "testparrot", "blobs")
+	require.NoError(t, os.MkdirAll(blobDir, 0770))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(blobDir, "abc"), make([]byte, 1000), 0660))
+
+	buf := &bytes.Buffer{}
+	w := gzip.NewWriter(buf)
+	_, err := w.Write(make([]byte, 2000))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	require.NoError(t, ioutil.WriteFile(filepath.Join(blobDir, "def.gz"), buf.Bytes(), 0660))
+
+	tests := []struct {
+		value    string
+		expected int
+	}{
+		{`"value"`, 7},
+		{`gotestparrot.Blob("testdata/testparrot/blobs/abc")`, 1000},
+		{`string(gotestparrot.Blob("testdata/testparrot/blobs/def.gz"))`, 2008},
+		{`[]interface{}{gotestparrot.Blob("testdata/testparrot/blobs/abc"), 1}`, 1018},
+	}
+
+	for _, test := range tests {
+		size, err := Recording{Value: test.value}.Size(dir)
+		require.NoError(t, err)
+		require.Equal(t, test.expected, size, test.value)
+	}
+
+	_, err = Recording{Value: `gotestparrot.Blob("testdata/testparrot/blobs/missing")`}.Size(dir)
+	require.Error(t, err)
+}
@@ -0,0 +1,70 @@
+// Package testdecl finds tests declared in test files of a package, using
+// same rules as go test, so recorder and command line tool agree on which
+// tests exist.
+package testdecl
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Find parses test files in a directory and returns names of files where
+// tests, benchmarks and fuzz targets are declared, by test name
+func Find(dir string) (map[string]string, error) {
+	filenames, err := filepath.Glob(filepath.Join(dir, "*_test.go"))
+	if err != nil {
+		return nil, err
+	}
+
+	declared := map[string]string{}
+
+	fset := token.NewFileSet()
+	for _, filename := range filenames {
+		astFile, err := parser.ParseFile(fset, filename, nil, parser.SkipObjectResolution)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, decl := range astFile.Decls {
+			funcDecl, ok := decl.(*ast.FuncDecl)
+			if !ok || funcDecl.Recv != nil {
+				continue
+			}
+
+			if IsTestName(funcDecl.Name.Name) {
+				declared[funcDecl.Name.Name] = filename
+			}
+		}
+	}
+
+	return declared, nil
+}
+
+// IsTestName checks whether name is a name of a top level test, benchmark or
+// fuzz target, using same rules as go test
+func IsTestName(name string) bool {
+	for _, prefix := range []string{"Test", "Benchmark", "Fuzz"} {
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+
+		if len(name) == len(prefix) {
+			return true
+		}
+
+		r, _ := utf8.DecodeRuneInString(name[len(prefix):])
+		return !unicode.IsLower(r)
+	}
+
+	return false
+}
+
+// TopLevel returns name of top level test of a test or subtest
+func TopLevel(name string) string {
+	return strings.Split(name, "/")[0]
+}
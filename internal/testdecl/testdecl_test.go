@@ -0,0 +1,53 @@
+package testdecl
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFind(t *testing.T) {
+	dir := t.TempDir()
+	src := "package example\n\n" +
+		"func TestA(t *testing.T) {}\n" +
+		"func BenchmarkA(b *testing.B) {}\n" +
+		"func FuzzA(f *testing.F) {}\n" +
+		"func (s suite) TestMethod(t *testing.T) {}\n" +
+		"func helper() {}\n"
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "a_test.go"), []byte(src), 0660))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "a.go"), []byte("package example\n\nfunc TestB() {}\n"), 0660))
+
+	declared, err := Find(dir)
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{
+		"TestA":      filepath.Join(dir, "a_test.go"),
+		"BenchmarkA": filepath.Join(dir, "a_test.go"),
+		"FuzzA":      filepath.Join(dir, "a_test.go"),
+	}, declared)
+}
+
+func TestIsTestName(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected bool
+	}{
+		{"Test", true},
+		{"TestA", true},
+		{"Test_underscore", true},
+		{"Testing", false},
+		{"BenchmarkA", true},
+		{"FuzzA", true},
+		{"helper", false},
+	}
+
+	for _, test := range tests {
+		require.Equal(t, test.expected, IsTestName(test.name), test.name)
+	}
+}
+
+func TestTopLevel(t *testing.T) {
+	require.Equal(t, "TestA", TopLevel("TestA"))
+	require.Equal(t, "TestA", TopLevel("TestA/sub/sub"))
+}
@@ -0,0 +1,216 @@
+package testparrot
+
+import (
+	"encoding"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"reflect"
+	"strings"
+)
+
+// Loss describes a part of a value that cannot be faithfully recorded, so
+// replayed value would not equal recorded value
+type Loss struct {
+	// Path defines path of a value that cannot be recorded, like
+	// .Dogs[1].owner
+	Path string
+
+	// Reason defines why value cannot be recorded
+	Reason string
+}
+
+func (l Loss) String() string {
+	path := l.Path
+	if path == "" {
+		path = "."
+	}
+
+	return fmt.Sprintf("%s: %s", path, l.Reason)
+}
+
+// formatLosses formats losses as a multiline string
+func formatLosses(losses []Loss) string {
+	lines := make([]string, 0, len(losses))
+	for _, loss := range losses {
+		lines = append(lines, "\t"+loss.String())
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// Losses method walks value and returns all parts of it that cannot be
+// generated as code, like non zero unexported fields, funcs, channels and
+// values in interfaces whose types cannot be referenced from generated
+// package.
+func (g *Generator) Losses(value interface{}) []Loss {
+	l := &lossFinder{g: g, visiting: map[visit]bool{}}
+
+	// recorded values are stored in interfaces
+	l.walk("", reflect.ValueOf(value), true)
+
+	return l.losses
+}
+
+type lossFinder struct {
+	g      *Generator
+	losses []Loss
+
+	// visiting defines pointers on path of currently walked value, used to
+	// detect cycles
+	visiting map[visit]bool
+}
+
+func (l *lossFinder) report(path string, format string, args ...interface{}) {
+	l.losses = append(l.losses, Loss{Path: path, Reason: fmt.Sprintf(format, args...)})
+}
+
+// walk walks value with path. Interface defines whether value is stored in an
+// interface, so its type must be referenced in generated code.
+func (l *lossFinder) walk(path string, value reflect.Value, inInterface bool) {
+	if !value.IsValid() {
+		return
+	}
+
+	if inInterface && !l.referenceable(value.Type()) {
+		l.report(path, "type '%v' in interface cannot be referenced in generated code", value.Type())
+		return
+	}
+
+	// values with constructors and serialized values are generated as
+	// they are
+	if _, ok := l.g.constructors[value.Type()]; ok {
+		return
+	}
+
+	switch value.Interface().(type) {
+	case encoding.TextMarshaler, json.Marshaler, encoding.BinaryMarshaler, gob.GobEncoder:
+		return
+	}
+
+	switch value.Kind() {
+	case reflect.Interface:
+		l.walk(path, value.Elem(), true)
+	case reflect.Ptr:
+		if value.IsNil() {
+			return
+		}
+
+		v := visit{value.Pointer(), 0, value.Type()}
+		if l.visiting[v] {
+			l.report(path, "cyclic pointer cannot be recorded")
+			return
+		}
+
+		l.visiting[v] = true
+		l.walk(path, value.Elem(), false)
+		delete(l.visiting, v)
+	case reflect.Struct:
+		for i := 0; i < value.NumField(); i++ {
+			field := value.Type().Field(i)
+			fieldPath := path + "." + field.Name
+
+			if field.PkgPath != "" {
+				if !value.Field(i).IsZero() {
+					l.report(fieldPath, "unexported field cannot be recorded, register constructor of '%v'", value.Type())
+				}
+
+				continue
+			}
+
+			if tag, err := parseTag(field); err == nil && (tag.omit || tag.redact) {
+				continue
+			}
+
+			l.walk(fieldPath, value.Field(i), false)
+		}
+	case reflect.Slice, reflect.Array:
+		// values of basic kinds cannot be lossy, so large byte slices are
+		// not walked element by element
+		if isBasicKind(value.Type().Elem().Kind()) {
+			return
+		}
+
+		for i := 0; i < value.Len(); i++ {
+			l.walk(fmt.Sprintf("%s[%d]", path, i), value.Index(i), false)
+		}
+	case reflect.Map:
+		iter := value.MapRange()
+		for iter.Next() {
+			keyPath := fmt.Sprintf("%s[%s]", path, formatValue(iter.Key()))
+			l.walk(keyPath, iter.Key(), false)
+			l.walk(keyPath, iter.Value(), false)
+		}
+	case reflect.Func:
+		if !value.IsNil() {
+			l.report(path, "funcs cannot be recorded")
+		}
+	case reflect.Chan:
+		if !value.IsNil() {
+			l.report(path, "channels cannot be recorded")
+		}
+	case reflect.UnsafePointer:
+		if !value.IsNil() {
+			l.report(path, "unsafe pointers cannot be recorded")
+		}
+	}
+}
+
+// referenceable returns whether type can be referenced in generated code.
+// Unexported types can only be referenced from their own package, and so can
+// unnamed structs with unexported fields.
+func (l *lossFinder) referenceable(typ reflect.Type) bool {
+	if typ.Name() != "" {
+		return typ.PkgPath() == "" || typ.PkgPath() == l.g.pkgPath || ast.IsExported(typ.Name())
+	}
+
+	switch typ.Kind() {
+	case reflect.Ptr, reflect.Slice, reflect.Array:
+		return l.referenceable(typ.Elem())
+	case reflect.Map:
+		return l.referenceable(typ.Key()) && l.referenceable(typ.Elem())
+	case reflect.Struct:
+		for i := 0; i < typ.NumField(); i++ {
+			field := typ.Field(i)
+			if field.PkgPath != "" && field.PkgPath != l.g.pkgPath {
+				return false
+			}
+
+			if !l.referenceable(field.Type) {
+				return false
+			}
+		}
+
+		return true
+	default:
+		return true
+	}
+}
+
+// isBasicKind returns whether kind is a kind of booleans, numbers or strings
+func isBasicKind(kind reflect.Kind) bool {
+	switch kind {
+	case
+		reflect.Bool,
+		reflect.Int,
+		reflect.Int8,
+		reflect.Int16,
+		reflect.Int32,
+		reflect.Int64,
+		reflect.Uint,
+		reflect.Uint8,
+		reflect.Uint16,
+		reflect.Uint32,
+		reflect.Uint64,
+		reflect.Uintptr,
+		reflect.Float32,
+		reflect.Float64,
+		reflect.Complex64,
+		reflect.Complex128,
+		reflect.String:
+		return true
+	default:
+		return false
+	}
+}
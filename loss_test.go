@@ -0,0 +1,132 @@
+package testparrot
+
+import (
+	"errors"
+	"testing"
+	"time"
+	"unsafe"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGeneratorLosses(t *testing.T) {
+	type handlers struct {
+		OnClose func()
+		Events  chan string
+		Pointer unsafe.Pointer
+		Skipped func() `testparrot:"-"`
+	}
+
+	type account struct {
+		Name     string
+		Balance  testMoney
+		Created  time.Time
+		Handlers *handlers
+		Values   map[string]interface{}
+		Next     *account
+		note     string
+	}
+
+	events := make(chan string)
+	value := 1
+
+	tests := []struct {
+		name     string
+		value    interface{}
+		expected []Loss
+	}{
+		{
+			name:  "lossless",
+			value: account{Name: "name", Created: time.Now(), Values: map[string]interface{}{"a": []int{1}}},
+		},
+		{
+			name:  "unexported fields",
+			value: []account{{Balance: testMoney{100, "EUR"}, note: "note"}},
+			expected: []Loss{
+				{"[0].Balance.amount", "unexported field cannot be recorded, register constructor of 'testparrot.testMoney'"},
+				{"[0].Balance.currency", "unexported field cannot be recorded, register constructor of 'testparrot.testMoney'"},
+				{"[0].note", "unexported field cannot be recorded, register constructor of 'testparrot.account'"},
+			},
+		},
+		{
+			name: "funcs and channels",
+			value: account{Handlers: &handlers{
+				OnClose: func() {},
+				Events:  events,
+				Pointer: unsafe.Pointer(&value),
+				Skipped: func() {},
+			}},
+			expected: []Loss{
+				{".Handlers.OnClose", "funcs cannot be recorded"},
+				{".Handlers.Events", "channels cannot be recorded"},
+				{".Handlers.Pointer", "unsafe pointers cannot be recorded"},
+			},
+		},
+		{
+			name:  "unexported type in interface",
+			value: account{Values: map[string]interface{}{"err": errors.New("error")}},
+			expected: []Loss{
+				{`.Values["err"]`, "type '*errors.errorString' in interface cannot be referenced in generated code"},
+			},
+		},
+		{
+			name:  "basic slices",
+			value: account{Values: map[string]interface{}{"data": make([]byte, 1024), "ids": [2]int{1, 2}}},
+		},
+		{
+			name: "cyclic pointer",
+			value: func() *account {
+				value := &account{}
+				value.Next = value
+				return value
+			}(),
+			expected: []Loss{{".Next", "cyclic pointer cannot be recorded"}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			g := NewGenerator(pkgPath, pkgName)
+			require.Equal(t, test.expected, g.Losses(test.value))
+		})
+	}
+
+	t.Run("constructor", func(t *testing.T) {
+		recorder := NewRecorder()
+		recorder.RegisterConstructor(newTestMoney, testMoneyArgs)
+
+		g := NewGenerator(pkgPath, pkgName)
+		g.constructors = recorder.getConstructors()
+		require.Empty(t, g.Losses(account{Balance: testMoney{100, "EUR"}}))
+	})
+
+	t.Run("unexported type of other package", func(t *testing.T) {
+		g := NewGenerator("example.com/other", "other")
+		require.Equal(t, []Loss{
+			{"", "type 'testparrot.testMoney' in interface cannot be referenced in generated code"},
+		}, g.Losses(testMoney{}))
+	})
+}
+
+func BenchmarkGeneratorLosses(b *testing.B) {
+	g := NewGenerator(pkgPath, pkgName)
+	value := make([]byte, 8<<20)
+
+	for i := 0; i < b.N; i++ {
+		g.Losses(value)
+	}
+}
+
+func TestRecorderRecordLossy(t *testing.T) {
+	recorder := NewRecorder()
+	recorder.EnableRecording(true)
+
+	ft := &fakeTB{TB: t}
+	require.Nil(t, recorder.Record(ft, "key", testMoney{100, "EUR"}))
+	require.Equal(t, []string{
+		"testparrot: value with key 'key' for test '" + t.Name() + "' cannot be recorded without losing data:\n" +
+			"\t.amount: unexported field cannot be recorded, register constructor of 'testparrot.testMoney'\n" +
+			"\t.currency: unexported field cannot be recorded, register constructor of 'testparrot.testMoney'",
+	}, ft.failures)
+	require.Empty(t, recorder.allRecordings[t.Name()])
+}
@@ -0,0 +1,204 @@
+package testparrot
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Normalizer rewrites a volatile value, like a timestamp or a generated ID,
+// into a stable placeholder. Normalizer must return a value assignable or
+// convertible to type of value it was given.
+type Normalizer func(value interface{}) interface{}
+
+// NormalizedTime defines placeholder of normalized timestamps
+var NormalizedTime = time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// NormalizedUUID defines placeholder of normalized UUID strings
+const NormalizedUUID = "00000000-0000-0000-0000-000000000000"
+
+// UUIDRegexp matches UUIDs in strings
+var UUIDRegexp = regexp.MustCompile(`(?i)[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}`)
+
+// NormalizeTime normalizer replaces timestamps with NormalizedTime
+func NormalizeTime(value interface{}) interface{} {
+	switch value.(type) {
+	case time.Time:
+		return NormalizedTime
+	case *time.Time:
+		normalized := NormalizedTime
+		return &normalized
+	}
+
+	return value
+}
+
+// NormalizeUUID normalizer replaces UUIDs with zero UUIDs. UUIDs are either
+// 16 byte arrays, like uuid.UUID, or UUIDs in strings.
+func NormalizeUUID(value interface{}) interface{} {
+	val := reflect.ValueOf(value)
+
+	switch {
+	case !val.IsValid():
+		return value
+	case val.Kind() == reflect.Array && val.Len() == 16 && val.Type().Elem().Kind() == reflect.Uint8:
+		return reflect.Zero(val.Type()).Interface()
+	case val.Kind() == reflect.Ptr && !val.IsNil() && val.Elem().Kind() == reflect.Array:
+		normalized := reflect.New(val.Type().Elem())
+		normalized.Elem().Set(reflect.ValueOf(NormalizeUUID(val.Elem().Interface())))
+		return normalized.Interface()
+	case val.Kind() == reflect.String:
+		return reflect.ValueOf(UUIDRegexp.ReplaceAllString(val.String(), NormalizedUUID)).Convert(val.Type()).Interface()
+	}
+
+	return value
+}
+
+// NormalizeDuration normalizer replaces durations with zero duration
+func NormalizeDuration(value interface{}) interface{} {
+	switch value.(type) {
+	case time.Duration:
+		return time.Duration(0)
+	case *time.Duration:
+		return new(time.Duration)
+	}
+
+	return value
+}
+
+// normalizers defines normalizers of a recorder
+type normalizers struct {
+	// types defines normalizers of values by their types
+	types map[reflect.Type]Normalizer
+
+	// fields defines normalizers of struct fields by their paths
+	fields map[string]Normalizer
+
+	// regexps defines normalizers of strings
+	regexps []regexpNormalizer
+}
+
+// regexpNormalizer replaces matches of regular expression in strings
+type regexpNormalizer struct {
+	re          *regexp.Regexp
+	replacement string
+}
+
+// NormalizeType method registers normalizer of values with type of sample
+// value, like time.Time{}
+func (r *Recorder) NormalizeType(sample interface{}, normalizer Normalizer) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.normalizers.types == nil {
+		r.normalizers.types = map[reflect.Type]Normalizer{}
+	}
+
+	r.normalizers.types[reflect.TypeOf(sample)] = normalizer
+}
+
+// NormalizeField method registers normalizer of values of struct field with
+// path, like .Meta.CreatedAt. Like with ScrubField, elements of slices,
+// arrays and maps are not part of the path.
+func (r *Recorder) NormalizeField(path string, normalizer Normalizer) {
+	if !strings.HasPrefix(path, ".") {
+		path = "." + path
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.normalizers.fields == nil {
+		r.normalizers.fields = map[string]Normalizer{}
+	}
+
+	r.normalizers.fields[path] = normalizer
+}
+
+// NormalizeRegexp method registers normalizer that replaces matches of
+// regular expression in strings with replacement, like
+// NormalizeRegexp(UUIDRegexp, NormalizedUUID)
+func (r *Recorder) NormalizeRegexp(re *regexp.Regexp, replacement string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.normalizers.regexps = append(r.normalizers.regexps, regexpNormalizer{re, replacement})
+}
+
+// empty returns whether there are no normalizers
+func (n normalizers) empty() bool {
+	return len(n.types) == 0 && len(n.fields) == 0 && len(n.regexps) == 0
+}
+
+// normalizeValue returns a copy of value with volatile values rewritten by
+// normalizers. Field normalizers take precedence over type normalizers, and
+// regular expressions are applied to strings that were not normalized
+// otherwise.
+func normalizeValue(n normalizers, constructors map[reflect.Type]constructor, value interface{}) (interface{}, error) {
+	if n.empty() || value == nil {
+		return value, nil
+	}
+
+	var err error
+	rw := newRewriter(constructors, func(path string, field *reflect.StructField, mapKey reflect.Value, value reflect.Value) (reflect.Value, bool) {
+		normalizer, ok := n.fields[path]
+		if !ok {
+			normalizer, ok = n.types[value.Type()]
+		}
+
+		if ok {
+			normalized, nerr := normalizedValue(value.Type(), normalizer(value.Interface()))
+			if nerr != nil && err == nil {
+				err = fmt.Errorf("normalizing value at path '%s': %v", path, nerr)
+			}
+
+			return normalized, nerr == nil
+		}
+
+		if value.Kind() != reflect.String || len(n.regexps) == 0 {
+			return value, false
+		}
+
+		str := value.String()
+		for _, rn := range n.regexps {
+			str = rn.re.ReplaceAllString(str, rn.replacement)
+		}
+
+		return reflect.ValueOf(str).Convert(value.Type()), true
+	})
+
+	// basic values are normalized by their types and strings by regular
+	// expressions
+	rw.rewritesBasic = func(typ reflect.Type) bool {
+		_, ok := n.types[typ]
+		return ok || (typ.Kind() == reflect.String && len(n.regexps) > 0)
+	}
+
+	result := rw.rewrite("", nil, reflect.Value{}, reflect.ValueOf(value))
+	if err != nil {
+		return nil, newErr(err)
+	}
+
+	return result.Interface(), nil
+}
+
+// normalizedValue converts value returned by normalizer to type of value
+// that was normalized
+func normalizedValue(typ reflect.Type, normalized interface{}) (reflect.Value, error) {
+	val := reflect.ValueOf(normalized)
+
+	switch {
+	case !val.IsValid():
+		return reflect.Zero(typ), nil
+	case val.Type().AssignableTo(typ):
+		result := reflect.New(typ).Elem()
+		result.Set(val)
+		return result, nil
+	case val.Type().ConvertibleTo(typ):
+		return val.Convert(typ), nil
+	default:
+		return reflect.Value{}, fmt.Errorf("normalizer returned value of type '%v', expected '%v'", val.Type(), typ)
+	}
+}
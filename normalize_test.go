@@ -0,0 +1,158 @@
+package testparrot
+
+import (
+	"reflect"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+type testEvent struct {
+	ID        uuid.UUID
+	RequestID string
+	Created   time.Time
+	Updated   *time.Time
+	Took      time.Duration
+	Nonce     string
+	Tags      []string
+}
+
+func TestNormalizers(t *testing.T) {
+	now := time.Now()
+
+	require.Equal(t, NormalizedTime, NormalizeTime(now))
+	require.Equal(t, &NormalizedTime, NormalizeTime(&now))
+	require.Equal(t, "value", NormalizeTime("value"))
+
+	id := uuid.New()
+	require.Equal(t, uuid.Nil, NormalizeUUID(id))
+	require.Equal(t, &uuid.Nil, NormalizeUUID(&id))
+	require.Equal(t, "id: "+NormalizedUUID, NormalizeUUID("id: "+id.String()))
+
+	took := time.Second
+	require.Equal(t, time.Duration(0), NormalizeDuration(took))
+	require.Equal(t, new(time.Duration), NormalizeDuration(&took))
+}
+
+func TestNormalizeValue(t *testing.T) {
+	now := time.Now()
+	event := testEvent{
+		ID:        uuid.New(),
+		RequestID: "request " + uuid.New().String(),
+		Created:   now,
+		Updated:   &now,
+		Took:      time.Second,
+		Nonce:     "nonce-1234",
+		Tags:      []string{"tag-1234"},
+	}
+
+	n := normalizers{
+		types: map[reflect.Type]Normalizer{
+			reflect.TypeOf(uuid.UUID{}): NormalizeUUID,
+			reflect.TypeOf(time.Time{}): NormalizeTime,
+			reflect.TypeOf(time.Second): NormalizeDuration,
+		},
+		fields: map[string]Normalizer{
+			".Nonce": func(interface{}) interface{} { return "nonce" },
+		},
+		regexps: []regexpNormalizer{
+			{UUIDRegexp, NormalizedUUID},
+			{regexp.MustCompile(`tag-\d+`), "tag-N"},
+		},
+	}
+
+	normalized, err := normalizeValue(n, nil, event)
+	require.NoError(t, err)
+	require.Equal(t, testEvent{
+		RequestID: "request " + NormalizedUUID,
+		Created:   NormalizedTime,
+		Updated:   &NormalizedTime,
+		Nonce:     "nonce",
+		Tags:      []string{"tag-N"},
+	}, normalized)
+
+	// original value is not modified
+	require.Equal(t, now, *event.Updated)
+
+	t.Run("invalid normalizer", func(t *testing.T) {
+		_, err := normalizeValue(normalizers{
+			fields: map[string]Normalizer{".Took": func(interface{}) interface{} { return "value" }},
+		}, nil, event)
+		require.EqualError(t, err,
+			"testparrot: normalizing value at path '.Took': normalizer returned value of type 'string', expected 'time.Duration'")
+	})
+
+	t.Run("basic slices", func(t *testing.T) {
+		normalized, err := normalizeValue(normalizers{
+			types: map[reflect.Type]Normalizer{reflect.TypeOf(time.Duration(0)): NormalizeDuration},
+		}, nil, []time.Duration{time.Second})
+		require.NoError(t, err)
+		require.Equal(t, []time.Duration{0}, normalized)
+	})
+
+	t.Run("constructor args", func(t *testing.T) {
+		recorder := NewRecorder()
+		recorder.RegisterConstructor(newTestMoney, testMoneyArgs)
+
+		normalized, err := normalizeValue(normalizers{
+			types: map[reflect.Type]Normalizer{reflect.TypeOf(int64(0)): func(interface{}) interface{} { return 0 }},
+		}, recorder.getConstructors(), newTestMoney(100, "EUR"))
+		require.NoError(t, err)
+		require.Equal(t, newTestMoney(0, "EUR"), normalized)
+	})
+}
+
+func TestRecorderNormalize(t *testing.T) {
+	newNormalizeRecorder := func() *Recorder {
+		recorder := NewRecorder()
+		recorder.NormalizeType(time.Time{}, NormalizeTime)
+		recorder.NormalizeField("Took", NormalizeDuration)
+		recorder.NormalizeRegexp(UUIDRegexp, NormalizedUUID)
+		return recorder
+	}
+
+	newEvent := func() testEvent {
+		return testEvent{
+			RequestID: uuid.New().String(),
+			Created:   time.Now(),
+			Took:      time.Duration(time.Now().UnixNano() % 1000),
+			Nonce:     "nonce",
+		}
+	}
+
+	normalized := testEvent{RequestID: NormalizedUUID, Created: NormalizedTime, Nonce: "nonce"}
+
+	t.Run("recording enabled", func(t *testing.T) {
+		recorder := newNormalizeRecorder()
+		recorder.EnableRecording(true)
+
+		event := newEvent()
+		require.Equal(t, event, recorder.Record(t, "key", event))
+		require.Equal(t, []Recording{{"key", normalized}}, recorder.allRecordings[t.Name()])
+	})
+
+	t.Run("expect normalizes actual values", func(t *testing.T) {
+		recorder := newNormalizeRecorder()
+		recorder.Load(t.Name(), []Recording{{"key", normalized}})
+
+		require.True(t, recorder.Expect(t, "key", newEvent()))
+	})
+
+	t.Run("expect reports differences of other values", func(t *testing.T) {
+		recorder := newNormalizeRecorder()
+		recorder.Load(t.Name(), []Recording{{"key", normalized}})
+
+		event := newEvent()
+		event.Nonce = "other"
+
+		ft := &fakeTB{TB: t}
+		require.False(t, recorder.Expect(ft, "key", event))
+		require.Equal(t, []string{
+			"testparrot: value differs from recording with key 'key' for test '" + t.Name() + "':\n" +
+				"\t.Nonce: recorded \"nonce\", actual \"other\"",
+		}, ft.failures)
+	})
+}
@@ -3,9 +3,54 @@ package testparrot
 import (
 	"fmt"
 	"path"
+	"reflect"
+	"runtime"
+	"strings"
+	"sync"
 	"testing"
 )
 
+// Mode defines recording mode of a recorder
+type Mode int
+
+const (
+	// ModeReplay replays all values from recordings
+	ModeReplay Mode = iota
+
+	// ModeRecord records all values, replacing recordings of tests that run
+	ModeRecord
+
+	// ModeMissing replays existing recordings and records only values that
+	// have no recording yet
+	ModeMissing
+)
+
+// modeNames defines names of recording modes, as used in flags
+var modeNames = map[Mode]string{
+	ModeReplay:  "replay",
+	ModeRecord:  "record",
+	ModeMissing: "missing",
+}
+
+func (m Mode) String() string {
+	if name, ok := modeNames[m]; ok {
+		return name
+	}
+
+	return fmt.Sprintf("Mode(%d)", int(m))
+}
+
+// ParseMode parses recording mode from its name
+func ParseMode(name string) (Mode, error) {
+	for mode, modeName := range modeNames {
+		if modeName == name {
+			return mode, nil
+		}
+	}
+
+	return ModeReplay, fmt.Errorf("unknown recording mode '%s'", name)
+}
+
 type Recording struct {
 	// Key defines record key
 	Key interface{}
@@ -14,50 +59,132 @@ type Recording struct {
 	Value interface{}
 }
 
+// SeqKey defines key of a recording in a named sequence
+type SeqKey struct {
+	// Seq defines name of a sequence
+	Seq string
+
+	// Index defines position of recording in a sequence
+	Index int
+}
+
+// Recorder records and replays test values. Recorder is safe for concurrent
+// use, so it can be used from parallel tests and from multiple goroutines
+// inside a single test.
+//
+// Sequential recordings get their keys in order in which calls to RecordNext
+// acquire the recorder, so sequences recorded from multiple goroutines of a
+// single test only replay deterministically if goroutines record in a fixed
+// order. Use RecordNextIn with a separate sequence for every goroutine if
+// order of goroutines is not fixed.
 type Recorder struct {
+	// mu guards all fields below
+	mu sync.Mutex
+
 	allRecordings map[string][]Recording
 
 	// counter defines counter for sequential recordings
 	counters map[string]int
 
+	// seqCounters defines counters for named sequential recordings
+	seqCounters map[string]map[string]int
+
 	// testFilenames where individual tests are
 	testFilenames map[string]string
 
-	// recordingEnabled defines whether recording is enabled
-	recordingEnabled bool
+	// loadedFilenames defines names of generated files recordings of
+	// individual tests were loaded from
+	loadedFilenames map[string]string
+
+	// recordedTests defines tests that recorded values in this run
+	recordedTests map[string]bool
+
+	// activeRuns defines tests with a run in progress
+	activeRuns map[string]bool
+
+	// runRecorded defines tests that recorded values in their current run
+	runRecorded map[string]bool
+
+	// usedKeys defines keys of recordings that were replayed by each test
+	usedKeys map[string]map[interface{}]bool
+
+	// inlineSnapshots defines values of ExpectInline calls that need to be
+	// written to test sources
+	inlineSnapshots []inlineSnapshot
+
+	// storage defines storage recordings of tests are loaded from, nil if
+	// recordings are only loaded by generated code
+	storage Storage
+
+	// storageLoaded defines tests whose recordings were loaded from storage,
+	// tests without stored recordings are set to false
+	storageLoaded map[string]bool
+
+	// scrubbers defines rules for replacing sensitive values in recordings
+	scrubbers []scrubber
+
+	// normalizers defines normalizers of volatile values in recordings
+	normalizers normalizers
+
+	// compareOptions defines options of comparisons of recorded and actual
+	// values
+	compareOptions []CompareOption
+
+	// constructors defines registered constructors of types with unexported
+	// fields
+	constructors map[reflect.Type]constructor
+
+	// mode defines recording mode
+	mode Mode
+
+	// panicsEnabled defines whether recorder panics on errors instead of
+	// failing the test
+	panicsEnabled bool
 }
 
 // NewRecoder creates a new Recorder
 func NewRecorder() *Recorder {
 	return &Recorder{
-		allRecordings: map[string][]Recording{},
-		counters:      map[string]int{},
-		testFilenames: map[string]string{},
+		allRecordings:   map[string][]Recording{},
+		counters:        map[string]int{},
+		seqCounters:     map[string]map[string]int{},
+		testFilenames:   map[string]string{},
+		loadedFilenames: map[string]string{},
+		recordedTests:   map[string]bool{},
+		activeRuns:      map[string]bool{},
+		runRecorded:     map[string]bool{},
+		usedKeys:        map[string]map[interface{}]bool{},
+		storageLoaded:   map[string]bool{},
 	}
 }
 
 // Reset method resets recorder
 func (r *Recorder) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
 	r.allRecordings = map[string][]Recording{}
 	r.counters = map[string]int{}
+	r.seqCounters = map[string]map[string]int{}
 	r.testFilenames = map[string]string{}
+	r.loadedFilenames = map[string]string{}
+	r.recordedTests = map[string]bool{}
+	r.activeRuns = map[string]bool{}
+	r.runRecorded = map[string]bool{}
+	r.usedKeys = map[string]map[interface{}]bool{}
+	r.inlineSnapshots = nil
+	r.storageLoaded = map[string]bool{}
 }
 
 // Recorder method records value under specified key. If recording is enabled
 // Record returns provided value, otherwise it returns already recorded value.
-func (r *Recorder) Record(t *testing.T, key interface{}, value interface{}) interface{} {
-	name := t.Name()
+func (r *Recorder) Record(t testing.TB, key interface{}, value interface{}) interface{} {
+	t.Helper()
 
-	testPath, err := getTestPath(t)
+	value, err := r.recordKey(t, key, value)
 	if err != nil {
-		panic(err)
-	}
-
-	r.testFilenames[name] = path.Base(testPath)
-
-	value, err = r.record(name, key, value)
-	if err != nil {
-		panic(err)
+		r.fail(t, err)
+		return nil
 	}
 
 	return value
@@ -65,91 +192,548 @@ func (r *Recorder) Record(t *testing.T, key interface{}, value interface{}) inte
 
 // RecordNext method records next value in sequence. If recording is enabled
 // Record returns provided value, otherwise it returns alreday recorded value.
-func (r *Recorder) RecordNext(t *testing.T, value interface{}) interface{} {
-	name := t.Name()
+func (r *Recorder) RecordNext(t testing.TB, value interface{}) interface{} {
+	t.Helper()
 
-	testPath, err := getTestPath(t)
+	_, value, err := r.recordNext(t, value)
 	if err != nil {
-		panic(err)
+		r.fail(t, err)
+		return nil
 	}
 
-	r.testFilenames[name] = path.Base(testPath)
+	return value
+}
 
-	if _, ok := r.counters[name]; !ok {
-		r.counters[name] = 0
-	}
+// RecordNextIn method records next value in a named sequence. Every sequence
+// has its own counter, so goroutines that use separate sequences replay
+// deterministically regardless of their scheduling.
+func (r *Recorder) RecordNextIn(t testing.TB, seq string, value interface{}) interface{} {
+	t.Helper()
 
-	value, err = r.record(name, r.counters[name], value)
+	value, err := r.recordNextIn(t, seq, value)
 	if err != nil {
-		panic(err)
+		r.fail(t, err)
+		return nil
 	}
 
-	// increase counter
-	r.counters[name]++
-
 	return value
 }
 
 // EnableRecording enables test recording
 func (r *Recorder) EnableRecording(enable bool) {
-	r.recordingEnabled = enable
+	if enable {
+		r.SetMode(ModeRecord)
+	} else {
+		r.SetMode(ModeReplay)
+	}
+}
+
+// SetMode sets recording mode
+func (r *Recorder) SetMode(mode Mode) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.mode = mode
+}
+
+// Mode returns recording mode
+func (r *Recorder) Mode() Mode {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.mode
+}
+
+// EnablePanics makes recorder panic on errors, instead of failing the test
+// with t.Fatalf
+func (r *Recorder) EnablePanics(enable bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.panicsEnabled = enable
 }
 
-// RecordingEnabled returns whether recording is enabled
+// PanicsEnabled returns whether recorder panics on errors
+func (r *Recorder) PanicsEnabled() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.panicsEnabled
+}
+
+// SetStorage sets storage recordings of tests are loaded from, when tests
+// first use recorder. Storage is also used to save recordings after tests.
+func (r *Recorder) SetStorage(storage Storage) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.storage = storage
+}
+
+// Storage returns storage of recordings, nil if recordings are stored as
+// generated go code
+func (r *Recorder) Storage() Storage {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.storage
+}
+
+// SetFormat sets format in which recordings are stored. Recordings in JSON
+// and YAML format are stored in golden files in GoldenDir, a file per test.
+func (r *Recorder) SetFormat(format Format) {
+	if format == FormatGo {
+		r.SetStorage(nil)
+	} else {
+		r.SetStorage(NewGoldenStorage(GoldenDir, format))
+	}
+}
+
+// RecordingEnabled returns whether recording is enabled, either for all or
+// only for missing recordings
 func (r *Recorder) RecordingEnabled() bool {
-	return r.recordingEnabled
+	return r.Mode() != ModeReplay
 }
 
 // Load method loads recording for a specific test name
 func (r *Recorder) Load(name string, recordings []Recording) {
+	// remember generated file recordings were loaded from, so only
+	// changed files can be regenerated
+	_, filename, _, _ := runtime.Caller(1)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
 	if _, ok := r.allRecordings[name]; ok {
 		panic(newErr(fmt.Errorf("recordings already loaded for test '%s'", name)))
 	}
 
 	r.allRecordings[name] = recordings
+
+	if strings.HasSuffix(filename, recordingFileSuffix) {
+		r.loadedFilenames[name] = path.Base(filename)
+	}
+}
+
+// Unused method returns recordings that were neither replayed nor recorded
+// by tests that ran in this run. Tests that did not use recorder at all ran
+// if they are selected by -test.run and -test.skip flags, or benchmarks by
+// -test.bench flag, except in short mode, where they could have been
+// skipped.
+func (r *Recorder) Unused() map[string][]Recording {
+	return r.unused(currentTestFilter())
+}
+
+// unused returns unused recordings of tests that used recorder or are
+// selected by filter
+func (r *Recorder) unused(filter testFilter) map[string][]Recording {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	result := map[string][]Recording{}
+	for name, recordings := range r.allRecordings {
+		used, ok := r.usedKeys[name]
+		if !ok && !filter.selects(name) {
+			continue
+		}
+
+		for _, recording := range recordings {
+			if !used[recording.Key] {
+				result[name] = append(result[name], recording)
+			}
+		}
+	}
+
+	return result
+}
+
+// loadedFiles returns a copy of names of generated files recordings were
+// loaded from
+func (r *Recorder) loadedFiles() map[string]string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	result := make(map[string]string, len(r.loadedFilenames))
+	for name, filename := range r.loadedFilenames {
+		result[name] = filename
+	}
+
+	return result
+}
+
+// recorded returns a copy of names of tests that recorded values in this run
+func (r *Recorder) recorded() map[string]bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	result := make(map[string]bool, len(r.recordedTests))
+	for name := range r.recordedTests {
+		result[name] = true
+	}
+
+	return result
+}
+
+// drop removes recordings of tests
+func (r *Recorder) drop(names []string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, name := range names {
+		delete(r.allRecordings, name)
+	}
+}
+
+// recordings returns a copy of all recordings
+func (r *Recorder) recordings() map[string][]Recording {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	result := make(map[string][]Recording, len(r.allRecordings))
+	for name, recordings := range r.allRecordings {
+		result[name] = append([]Recording(nil), recordings...)
+	}
+
+	return result
+}
+
+// filenames returns a copy of filenames of recorded tests
+func (r *Recorder) filenames() map[string]string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	result := make(map[string]string, len(r.testFilenames))
+	for name, filename := range r.testFilenames {
+		result[name] = filename
+	}
+
+	return result
+}
+
+// beginRun starts a new run of a test, the first time test uses recorder in
+// this run. When run finishes, sequence counters of the test are reset, so
+// next run of the same test, like with go test -count, starts from scratch.
+// Benchmark functions are invoked multiple times and their cleanup runs after
+// every invocation, so every invocation is a separate run.
+func (r *Recorder) beginRun(t testing.TB, name string) {
+	if r.activeRuns[name] {
+		return
+	}
+
+	r.activeRuns[name] = true
+
+	t.Cleanup(func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+
+		delete(r.activeRuns, name)
+		delete(r.runRecorded, name)
+		delete(r.counters, name)
+		delete(r.seqCounters, name)
+	})
+}
+
+// fail reports error through test, or panics if panics are enabled
+func (r *Recorder) fail(t testing.TB, err error) {
+	t.Helper()
+
+	if r.PanicsEnabled() {
+		panic(err)
+	}
+
+	t.Fatalf("%v", err)
+}
+
+func (r *Recorder) recordKey(t testing.TB, key interface{}, value interface{}) (interface{}, error) {
+	name := t.Name()
+
+	testPath, err := getTestPath(t)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.testFilenames[name] = path.Base(testPath)
+	r.beginRun(t, name)
+
+	return r.record(name, key, value)
+}
+
+// recordNext records next value in sequence and returns key under which
+// value was recorded
+func (r *Recorder) recordNext(t testing.TB, value interface{}) (int, interface{}, error) {
+	name := t.Name()
+
+	testPath, err := getTestPath(t)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.testFilenames[name] = path.Base(testPath)
+	r.beginRun(t, name)
+
+	key := r.counters[name]
+
+	value, err = r.record(name, key, value)
+	if err != nil {
+		return key, nil, err
+	}
+
+	// increase counter
+	r.counters[name]++
+
+	return key, value, nil
+}
+
+func (r *Recorder) recordNextIn(t testing.TB, seq string, value interface{}) (interface{}, error) {
+	name := t.Name()
+
+	testPath, err := getTestPath(t)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.testFilenames[name] = path.Base(testPath)
+	r.beginRun(t, name)
+
+	if _, ok := r.seqCounters[name]; !ok {
+		r.seqCounters[name] = map[string]int{}
+	}
+
+	key := SeqKey{Seq: seq, Index: r.seqCounters[name][seq]}
+
+	value, err = r.record(name, key, value)
+	if err != nil {
+		return nil, err
+	}
+
+	// increase counter
+	r.seqCounters[name][seq]++
+
+	return value, nil
 }
 
 func (r *Recorder) record(name string, key interface{}, value interface{}) (interface{}, error) {
-	if !r.recordingEnabled {
-		value, err := r.getRecordValue(name, key)
+	if err := r.loadStorage(name); err != nil {
+		return nil, err
+	}
+
+	switch r.mode {
+	case ModeReplay:
+		value, err := r.getRecordValue(name, key, value)
 		if err != nil {
 			return nil, err
 		}
 
 		return value, nil
+	case ModeMissing:
+		// existing recordings are replayed and missing are merged with them,
+		// while recordings that cannot be replayed are reported
+		if r.hasRecording(name, key) {
+			return r.getRecordValue(name, key, value)
+		}
+	case ModeRecord:
+		// recordings of a test that records again are replaced, while
+		// recordings of all other tests are kept. Every run of a test, like
+		// with go test -count or when retrying, replaces previous run.
+		if !r.runRecorded[name] {
+			delete(r.allRecordings, name)
+		}
+	}
+
+	// recordings are normalized and scrubbed, while test keeps using its
+	// own value
+	cleaned, err := r.cleanValue(value)
+	if err != nil {
+		return nil, err
+	}
+
+	// lossy recordings would never replay equal, so test fails right away
+	losses, err := r.losses(cleaned)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(losses) > 0 {
+		return nil, newErr(fmt.Errorf("value with key '%v' for test '%s' cannot be recorded without losing data:\n%s",
+			key, name, formatLosses(losses)))
 	}
 
-	if err := r.setRecordValue(name, key, value); err != nil {
+	if err := r.setRecordValue(name, key, cleaned); err != nil {
 		return nil, err
 	}
 
 	return value, nil
 }
 
-func (r *Recorder) getRecordValue(name string, key interface{}) (interface{}, error) {
-	if records, ok := r.allRecordings[name]; ok {
-		for _, record := range records {
-			if record.Key == key {
-				return record.Value, nil
-			}
+// losses returns parts of value that storage of recorder cannot store. Values
+// stored as generated go code are checked by generator, other storages check
+// values if they implement LossFinder.
+func (r *Recorder) losses(value interface{}) ([]Loss, error) {
+	switch storage := r.storage.(type) {
+	case LossFinder:
+		return storage.Losses(value), nil
+	case nil, *GoStorage:
+		_, _, pkgPath, err := getCallSite()
+		if err != nil {
+			return nil, newErr(err)
 		}
+
+		g := &Generator{pkgPath: pkgPath, constructors: r.constructors}
+		return g.Losses(value), nil
+	default:
+		return nil, nil
 	}
+}
 
-	return nil, newErr(fmt.Errorf("recording with key '%v' not found for test '%s'", key, name))
+// clean returns a copy of value with volatile values normalized and
+// sensitive values scrubbed
+func (r *Recorder) clean(value interface{}) (interface{}, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.cleanValue(value)
 }
 
-func (r *Recorder) setRecordValue(name string, key interface{}, value interface{}) error {
+// cleanValue normalizes and scrubs value, like clean, with recorder already
+// locked
+func (r *Recorder) cleanValue(value interface{}) (interface{}, error) {
+	value, err := normalizeValue(r.normalizers, r.constructors, value)
+	if err != nil {
+		return nil, err
+	}
+
+	return scrubValue(r.scrubbers, r.constructors, value), nil
+}
+
+// storageFiles returns a copy of tests loaded from storage, with whether
+// they had stored recordings
+func (r *Recorder) storageFiles() map[string]bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	result := make(map[string]bool, len(r.storageLoaded))
+	for name, loaded := range r.storageLoaded {
+		result[name] = loaded
+	}
+
+	return result
+}
+
+// loadStorage loads recordings of a test from storage, the first time test
+// uses recorder
+func (r *Recorder) loadStorage(name string) error {
+	if r.storage == nil {
+		return nil
+	}
+
+	if _, ok := r.storageLoaded[name]; ok {
+		return nil
+	}
+
+	recordings, err := r.storage.Load(name)
+	if err != nil {
+		return newErr(err)
+	}
+
+	r.storageLoaded[name] = recordings != nil
+
+	if _, ok := r.allRecordings[name]; !ok && recordings != nil {
+		r.allRecordings[name] = recordings
+	}
+
+	return nil
+}
+
+// loadAllStorage loads recordings of all tests from storage, so recordings
+// of tests that did not run are known too
+func (r *Recorder) loadAllStorage() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.storage == nil {
+		return nil
+	}
+
+	names, err := r.storage.List()
+	if err != nil {
+		return newErr(err)
+	}
+
+	for _, name := range names {
+		if err := r.loadStorage(name); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// markUsed marks recording of a test as used in this run
+func (r *Recorder) markUsed(name string, key interface{}) {
+	if _, ok := r.usedKeys[name]; !ok {
+		r.usedKeys[name] = map[interface{}]bool{}
+	}
+
+	r.usedKeys[name][key] = true
+}
+
+// getRecordValue returns recorded value. Values loaded from golden files are
+// decoded into type of hint, which is value passed by test.
+// hasRecording returns whether test has a recording with key
+func (r *Recorder) hasRecording(name string, key interface{}) bool {
+	for _, record := range r.allRecordings[name] {
+		if keysEqual(record.Key, key) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (r *Recorder) getRecordValue(name string, key interface{}, hint interface{}) (interface{}, error) {
 	if records, ok := r.allRecordings[name]; ok {
-		for _, record := range records {
-			if record.Key == key {
-				return fmt.Errorf("recording with key '%v' already exists for test '%s'", key, name)
+		for i, record := range records {
+			if !keysEqual(record.Key, key) {
+				continue
 			}
+
+			if raw, ok := record.Value.(*rawValue); ok {
+				value, err := raw.decode(hint)
+				if err != nil {
+					return nil, newErr(fmt.Errorf("decoding recording with key '%v' for test '%s': %v", key, name, err))
+				}
+
+				records[i] = Recording{key, value}
+			}
+
+			r.markUsed(name, key)
+			return records[i].Value, nil
 		}
+	}
 
-		r.allRecordings[name] = append(records, Recording{key, value})
-	} else {
-		r.allRecordings[name] = []Recording{{key, value}}
+	return nil, newErr(fmt.Errorf("recording with key '%v' not found for test '%s'", key, name))
+}
+
+func (r *Recorder) setRecordValue(name string, key interface{}, value interface{}) error {
+	if r.hasRecording(name, key) {
+		return fmt.Errorf("recording with key '%v' already exists for test '%s'", key, name)
 	}
 
+	// test is only marked as recorded, when value was recorded
+	r.recordedTests[name] = true
+	r.runRecorded[name] = true
+	r.markUsed(name, key)
+
+	r.allRecordings[name] = append(r.allRecordings[name], Recording{key, value})
+
 	return nil
 }
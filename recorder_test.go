@@ -2,11 +2,43 @@ package testparrot
 
 import (
 	"fmt"
+	"sync"
 	"testing"
 
 	"github.com/stretchr/testify/require"
 )
 
+// fakeTB captures failures and cleanups of a test, so they can be asserted
+type fakeTB struct {
+	testing.TB
+
+	failures []string
+	cleanups []func()
+}
+
+func (t *fakeTB) Helper() {}
+
+func (t *fakeTB) Cleanup(f func()) {
+	t.cleanups = append(t.cleanups, f)
+}
+
+// finish finishes run of a fake test by running its cleanups
+func (t *fakeTB) finish() {
+	for i := len(t.cleanups) - 1; i >= 0; i-- {
+		t.cleanups[i]()
+	}
+
+	t.cleanups = nil
+}
+
+func (t *fakeTB) Fatalf(format string, args ...interface{}) {
+	t.failures = append(t.failures, fmt.Sprintf(format, args...))
+}
+
+func (t *fakeTB) Errorf(format string, args ...interface{}) {
+	t.failures = append(t.failures, fmt.Sprintf(format, args...))
+}
+
 func TestNewRecorder(t *testing.T) {
 	recorder := NewRecorder()
 	require.IsType(t, &Recorder{}, recorder)
@@ -54,9 +86,41 @@ func TestRecorderRecord(t *testing.T) {
 		require.Contains(t, recorder.allRecordings[t.Name()], Recording{"key2", "value2"})
 	})
 
+	t.Run("fails on duplicate key when recording enabled", func(t *testing.T) {
+		recorder := NewRecorder()
+		recorder.EnableRecording(true)
+
+		ft := &fakeTB{TB: t}
+		recorder.Record(ft, "key1", "value1")
+		recorder.Record(ft, "key1", "value1")
+		require.Equal(t, []string{
+			fmt.Sprintf("recording with key '%s' already exists for test '%s'", "key1", t.Name()),
+		}, ft.failures)
+	})
+
+	t.Run("rejected duplicate key does not mark test recorded", func(t *testing.T) {
+		recorder := NewRecorder()
+		recorder.Load(t.Name(), []Recording{{"key1", "value1"}})
+
+		require.Error(t, recorder.setRecordValue(t.Name(), "key1", "value1"))
+		require.Empty(t, recorder.recorded())
+		require.Empty(t, recorder.usedKeys[t.Name()])
+	})
+
+	t.Run("fails on missing key if not recording", func(t *testing.T) {
+		recorder := NewRecorder()
+
+		ft := &fakeTB{TB: t}
+		require.Nil(t, recorder.Record(ft, "key1", "value1"))
+		require.Equal(t, []string{
+			fmt.Sprintf("testparrot: recording with key '%s' not found for test '%s'", "key1", t.Name()),
+		}, ft.failures)
+	})
+
 	t.Run("panics on duplicate key when recording enabled", func(t *testing.T) {
 		recorder := NewRecorder()
 		recorder.EnableRecording(true)
+		recorder.EnablePanics(true)
 		require.PanicsWithError(t,
 			fmt.Sprintf("recording with key '%s' already exists for test '%s'", "key1", t.Name()),
 			func() {
@@ -68,6 +132,7 @@ func TestRecorderRecord(t *testing.T) {
 
 	t.Run("panics on missing key if not recording", func(t *testing.T) {
 		recorder := NewRecorder()
+		recorder.EnablePanics(true)
 		require.PanicsWithError(t,
 			fmt.Sprintf("testparrot: recording with key '%s' not found for test '%s'", "key1", t.Name()),
 			func() {
@@ -82,6 +147,47 @@ func TestRecorderRecord(t *testing.T) {
 		recorder.Load(t.Name(), []Recording{{"key", value}})
 		require.Equal(t, recorder.Record(t, "key", "value1"), value)
 	})
+
+	t.Run("replaces loaded recordings of test", func(t *testing.T) {
+		recorder := NewRecorder()
+		recorder.Load(t.Name(), []Recording{{"key1", "old1"}, {"key2", "old2"}})
+		recorder.Load("other", []Recording{{"key1", "other"}})
+		recorder.EnableRecording(true)
+
+		require.Equal(t, "new1", recorder.Record(t, "key1", "new1"))
+		require.Equal(t, []Recording{{"key1", "new1"}}, recorder.allRecordings[t.Name()])
+		require.Equal(t, []Recording{{"key1", "other"}}, recorder.allRecordings["other"])
+	})
+}
+
+func TestRecorderRecordMissing(t *testing.T) {
+	recorder := NewRecorder()
+	recorder.Load(t.Name(), []Recording{{"key1", "old1"}, {0, "old2"}})
+	recorder.SetMode(ModeMissing)
+
+	require.Equal(t, "old1", recorder.Record(t, "key1", "new1"))
+	require.Equal(t, "new2", recorder.Record(t, "key2", "new2"))
+	require.Equal(t, "old2", recorder.RecordNext(t, "new3"))
+	require.Equal(t, "new4", recorder.RecordNext(t, "new4"))
+	require.Equal(t, []Recording{
+		{"key1", "old1"}, {0, "old2"}, {"key2", "new2"}, {1, "new4"},
+	}, recorder.allRecordings[t.Name()])
+	require.True(t, recorder.recorded()[t.Name()])
+
+	t.Run("corrupt recording", func(t *testing.T) {
+		corrupt := &rawValue{"string", []byte(`{`)}
+
+		recorder := NewRecorder()
+		recorder.Load(t.Name(), []Recording{{"key", corrupt}})
+		recorder.SetMode(ModeMissing)
+
+		// recordings that cannot be decoded are reported, not recorded again
+		ft := &fakeTB{TB: t}
+		require.Nil(t, recorder.Record(ft, "key", "new"))
+		require.Len(t, ft.failures, 1)
+		require.Contains(t, ft.failures[0], "decoding recording with key 'key' for test '"+t.Name()+"'")
+		require.Equal(t, []Recording{{"key", corrupt}}, recorder.allRecordings[t.Name()])
+	})
 }
 
 func TestRecorderRecordNext(t *testing.T) {
@@ -95,8 +201,19 @@ func TestRecorderRecordNext(t *testing.T) {
 		require.Contains(t, recorder.allRecordings[t.Name()], Recording{1, "value2"})
 	})
 
+	t.Run("fails on missing key if not recording", func(t *testing.T) {
+		recorder := NewRecorder()
+
+		ft := &fakeTB{TB: t}
+		recorder.RecordNext(ft, "key1")
+		require.Equal(t, []string{
+			fmt.Sprintf("testparrot: recording with key '%s' not found for test '%s'", "0", t.Name()),
+		}, ft.failures)
+	})
+
 	t.Run("panics on missing key if not recording", func(t *testing.T) {
 		recorder := NewRecorder()
+		recorder.EnablePanics(true)
 		require.PanicsWithError(t,
 			fmt.Sprintf("testparrot: recording with key '%s' not found for test '%s'", "0", t.Name()),
 			func() {
@@ -113,8 +230,242 @@ func TestRecorderRecordNext(t *testing.T) {
 	})
 }
 
+func TestRecorderRecordNextIn(t *testing.T) {
+	t.Run("recording enabled", func(t *testing.T) {
+		recorder := NewRecorder()
+		recorder.EnableRecording(true)
+		require.Equal(t, "value1", recorder.RecordNextIn(t, "seq1", "value1"))
+		require.Equal(t, "value2", recorder.RecordNextIn(t, "seq2", "value2"))
+		require.Equal(t, "value3", recorder.RecordNextIn(t, "seq1", "value3"))
+		require.Contains(t, recorder.allRecordings[t.Name()], Recording{SeqKey{"seq1", 0}, "value1"})
+		require.Contains(t, recorder.allRecordings[t.Name()], Recording{SeqKey{"seq2", 0}, "value2"})
+		require.Contains(t, recorder.allRecordings[t.Name()], Recording{SeqKey{"seq1", 1}, "value3"})
+	})
+
+	t.Run("reply values", func(t *testing.T) {
+		recorder := NewRecorder()
+		recorder.Load(t.Name(), []Recording{{SeqKey{"seq", 0}, "value"}})
+		require.Equal(t, "value", recorder.RecordNextIn(t, "seq", "value1"))
+	})
+}
+
+func TestRecorderMultipleRuns(t *testing.T) {
+	t.Run("replay", func(t *testing.T) {
+		recorder := NewRecorder()
+		recorder.Load(t.Name(), []Recording{{0, "value1"}, {1, "value2"}, {SeqKey{"seq", 0}, "value3"}})
+
+		ft := &fakeTB{TB: t}
+		for run := 0; run < 2; run++ {
+			require.Equal(t, "value1", recorder.RecordNext(ft, ""))
+			require.Equal(t, "value2", recorder.RecordNext(ft, ""))
+			require.Equal(t, "value3", recorder.RecordNextIn(ft, "seq", ""))
+			ft.finish()
+		}
+
+		require.Empty(t, ft.failures)
+	})
+
+	t.Run("record", func(t *testing.T) {
+		recorder := NewRecorder()
+		recorder.EnableRecording(true)
+
+		ft := &fakeTB{TB: t}
+		recorder.Record(ft, "key", "value1")
+		recorder.RecordNext(ft, "value2")
+		recorder.RecordNext(ft, "value3")
+		ft.finish()
+
+		// retried run records less values, which replace previous run
+		recorder.Record(ft, "key", "value4")
+		recorder.RecordNext(ft, "value5")
+		ft.finish()
+
+		require.Empty(t, ft.failures)
+		require.Equal(t, []Recording{{"key", "value4"}, {0, "value5"}}, recorder.allRecordings[t.Name()])
+	})
+
+	t.Run("duplicate key in single run", func(t *testing.T) {
+		recorder := NewRecorder()
+		recorder.EnableRecording(true)
+
+		ft := &fakeTB{TB: t}
+		recorder.Record(ft, "key", "value1")
+		recorder.Record(ft, "key", "value2")
+		require.Len(t, ft.failures, 1)
+	})
+}
+
+func TestRecorderConcurrent(t *testing.T) {
+	recorder := NewRecorder()
+	recorder.EnableRecording(true)
+
+	t.Run("group", func(t *testing.T) {
+		for i := 0; i < 10; i++ {
+			i := i
+			t.Run(fmt.Sprintf("parallel%d", i), func(t *testing.T) {
+				t.Parallel()
+
+				var wg sync.WaitGroup
+				for j := 0; j < 10; j++ {
+					j := j
+					wg.Add(1)
+					go func() {
+						defer wg.Done()
+						recorder.RecordNext(t, i*10+j)
+						recorder.RecordNextIn(t, fmt.Sprintf("seq%d", j), j)
+					}()
+				}
+				wg.Wait()
+			})
+		}
+	})
+
+	recordings := recorder.recordings()
+	require.Len(t, recordings, 10)
+	for _, testRecordings := range recordings {
+		require.Len(t, testRecordings, 20)
+	}
+}
+
+func BenchmarkRecorderRecord(b *testing.B) {
+	recorder := NewRecorder()
+	recorder.Load(b.Name(), []Recording{{"key", "value"}})
+
+	for i := 0; i < b.N; i++ {
+		require.Equal(b, "value", recorder.Record(b, "key", "value1"))
+	}
+}
+
+func BenchmarkRecorderRecordNext(b *testing.B) {
+	recorder := NewRecorder()
+	recorder.Load(b.Name(), []Recording{{0, "value1"}, {1, "value2"}})
+
+	// benchmark function is invoked multiple times, but every invocation
+	// replays sequence from start
+	require.Equal(b, "value1", recorder.RecordNext(b, ""))
+
+	for i := 0; i < b.N; i++ {
+		require.Equal(b, "value2", recorder.Record(b, 1, ""))
+	}
+}
+
+func FuzzRecorderRecord(f *testing.F) {
+	recorder := NewRecorder()
+	recorder.EnableRecording(true)
+
+	f.Add("value1")
+	f.Add("value2")
+	f.Fuzz(func(t *testing.T, value string) {
+		require.Equal(t, value, recorder.Record(t, "key", value))
+		require.Contains(t, recorder.recordings()[t.Name()], Recording{"key", value})
+	})
+}
+
+func TestRecorderUnused(t *testing.T) {
+	t.Run("replay", func(t *testing.T) {
+		recorder := NewRecorder()
+		recorder.Load(t.Name(), []Recording{{0, "value1"}, {1, "value2"}, {"key", "value3"}})
+		recorder.Load("TestOther", []Recording{{0, "value"}})
+
+		recorder.RecordNext(t, "value1")
+		require.Equal(t, map[string][]Recording{
+			t.Name(): {{1, "value2"}, {"key", "value3"}},
+		}, recorder.unused(testFilter{run: "^TestRecorderUnused$"}))
+	})
+
+	t.Run("record missing", func(t *testing.T) {
+		recorder := NewRecorder()
+		recorder.Load(t.Name(), []Recording{{"key1", "value1"}, {"key2", "value2"}})
+		recorder.SetMode(ModeMissing)
+
+		recorder.Record(t, "key1", "value1")
+		recorder.Record(t, "key3", "value3")
+		require.Equal(t, map[string][]Recording{
+			t.Name(): {{"key2", "value2"}},
+		}, recorder.unused(testFilter{}))
+	})
+
+	t.Run("record", func(t *testing.T) {
+		recorder := NewRecorder()
+		recorder.Load(t.Name(), []Recording{{"key1", "value1"}, {"key2", "value2"}})
+		recorder.EnableRecording(true)
+
+		recorder.Record(t, "key1", "value1")
+		require.Empty(t, recorder.unused(testFilter{}))
+	})
+
+	t.Run("tests that did not use recorder", func(t *testing.T) {
+		recorder := NewRecorder()
+		recorder.Load("TestA", []Recording{{0, "value"}})
+		recorder.Load("TestA/sub", []Recording{{0, "value"}})
+		recorder.Load("TestB", []Recording{{0, "value"}})
+
+		require.Equal(t, map[string][]Recording{
+			"TestA":     {{0, "value"}},
+			"TestA/sub": {{0, "value"}},
+			"TestB":     {{0, "value"}},
+		}, recorder.unused(testFilter{}))
+
+		require.Equal(t, map[string][]Recording{
+			"TestA": {{0, "value"}},
+		}, recorder.unused(testFilter{run: "TestA", skip: "TestA/sub"}))
+
+		// tests could have been skipped in short mode
+		require.Empty(t, recorder.unused(testFilter{short: true}))
+	})
+
+	t.Run("benchmarks and fuzz tests that did not use recorder", func(t *testing.T) {
+		recorder := NewRecorder()
+		recorder.Load("BenchmarkA", []Recording{{0, "value"}})
+		recorder.Load("BenchmarkB/sub", []Recording{{0, "value"}})
+		recorder.Load("FuzzA", []Recording{{0, "value"}})
+
+		// benchmarks run only when selected by bench flag, while seed
+		// corpus of fuzz tests runs with tests
+		require.Equal(t, map[string][]Recording{
+			"FuzzA": {{0, "value"}},
+		}, recorder.unused(testFilter{}))
+
+		require.Equal(t, map[string][]Recording{
+			"BenchmarkB/sub": {{0, "value"}},
+		}, recorder.unused(testFilter{run: "^$", bench: "BenchmarkB"}))
+
+		require.Equal(t, map[string][]Recording{
+			"BenchmarkA": {{0, "value"}},
+			"FuzzA":      {{0, "value"}},
+		}, recorder.unused(testFilter{bench: ".", skip: "BenchmarkB"}))
+	})
+}
+
 func TestEnableRecording(t *testing.T) {
 	recorder := NewRecorder()
 	recorder.EnableRecording(true)
 	require.True(t, recorder.RecordingEnabled())
 }
+
+func TestSetMode(t *testing.T) {
+	recorder := NewRecorder()
+	require.Equal(t, ModeReplay, recorder.Mode())
+	require.False(t, recorder.RecordingEnabled())
+
+	recorder.SetMode(ModeMissing)
+	require.Equal(t, ModeMissing, recorder.Mode())
+	require.True(t, recorder.RecordingEnabled())
+}
+
+func TestParseMode(t *testing.T) {
+	for _, mode := range []Mode{ModeReplay, ModeRecord, ModeMissing} {
+		parsed, err := ParseMode(mode.String())
+		require.NoError(t, err)
+		require.Equal(t, mode, parsed)
+	}
+
+	_, err := ParseMode("unknown")
+	require.EqualError(t, err, "unknown recording mode 'unknown'")
+}
+
+func TestEnablePanics(t *testing.T) {
+	recorder := NewRecorder()
+	recorder.EnablePanics(true)
+	require.True(t, recorder.PanicsEnabled())
+}
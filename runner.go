@@ -3,31 +3,63 @@ package testparrot
 import (
 	"flag"
 	"fmt"
+	"io"
 	"os"
-	"path"
 	"path/filepath"
+	"regexp"
+	"sort"
 	"strings"
 	"testing"
+
+	"github.com/xtruder/go-testparrot/internal/testdecl"
 )
 
 var (
 	enableRecordingFlag *bool
+	modeFlag            *string
 	splitFilesFlag      *bool
 	destFlag            *string
 	filenameFlag        *string
 	pkgPathFlag         *string
 	pkgNameFlag         *string
+	strictFlag          *bool
+	dropOrphanedFlag    *bool
+	checkFlag           *bool
+	reviewFlag          *bool
+	usageFileFlag       *string
+	formatFlag          *string
+	blobThresholdFlag   *int
+	blobGzipFlag        *bool
 )
 
+// osExit exits test binary, it is replaced in tests
+var osExit = os.Exit
+
+// recordingFileSuffix defines suffix of generated recording files
+const recordingFileSuffix = "_recording_test.go"
+
+// PendingFileSuffix defines suffix of files with pending recordings, which
+// are written next to generated files in review mode
+const PendingFileSuffix = ".pending"
+
 func defineTestparrotFlags() {
 	// if flags have not been yet define, define them
 	if flag.Lookup("testparrot.record") == nil {
 		enableRecordingFlag = flag.Bool("testparrot.record", false, "whether to enable testparrot recording")
+		modeFlag = flag.String("testparrot.mode", ModeReplay.String(), "recording mode: replay, record or missing")
+		formatFlag = flag.String("testparrot.format", FormatGo.String(), "recording format: go, json or yaml")
+		blobThresholdFlag = flag.Int("testparrot.blobthreshold", 0, "size in bytes from which strings and byte slices are stored in blob files, 0 disables blob files")
+		blobGzipFlag = flag.Bool("testparrot.blobgzip", false, "whether to compress blob files with gzip")
 		splitFilesFlag = flag.Bool("testparrot.splitfiles", false, "whether to split tests into multiple files")
 		destFlag = flag.String("testparrot.dest", "", "override destination path")
 		filenameFlag = flag.String("testparrot.filename", "", "override destination filename")
 		pkgPathFlag = flag.String("testparrot.pkgpath", "", "override package path")
 		pkgNameFlag = flag.String("testparrot.pkgname", "", "override package name")
+		strictFlag = flag.Bool("testparrot.strict", false, "whether to fail when unused recordings are found")
+		checkFlag = flag.Bool("testparrot.check", false, "whether to check that recordings are up to date, without writing them")
+		reviewFlag = flag.Bool("testparrot.review", false, "whether to write changed recordings to pending files for review")
+		usageFileFlag = flag.String("testparrot.usagefile", "", "write usage of recordings to file, as used by testparrot prune")
+		dropOrphanedFlag = flag.Bool("testparrot.droporphaned", false, "whether to drop recordings of tests that no longer exist when recording")
 	}
 }
 
@@ -62,19 +94,281 @@ func beforeTests(recorder *Recorder) {
 	flag.Parse()
 
 	if !recorder.RecordingEnabled() {
-		recorder.EnableRecording(*enableRecordingFlag)
+		mode, err := ParseMode(*modeFlag)
+		if err != nil {
+			panic(newErr(err))
+		}
+
+		// -testparrot.record is a shorthand for -testparrot.mode=record
+		if *enableRecordingFlag {
+			mode = ModeRecord
+		}
+
+		// check and review modes need recorded values, so they can be
+		// compared with generated files
+		if (*checkFlag || *reviewFlag) && mode == ModeReplay {
+			mode = ModeRecord
+		}
+
+		recorder.SetMode(mode)
 	}
 
-	// reset loaded values if recording is enabled
-	if recorder.RecordingEnabled() {
-		recorder.Reset()
+	// storage configured on recorder takes precedence over format flag
+	if recorder.Storage() == nil {
+		format, err := ParseFormat(*formatFlag)
+		if err != nil {
+			panic(newErr(err))
+		}
+
+		recorder.SetFormat(format)
 	}
 }
 
 func afterTests(recorder *Recorder, recorderVar string, skip int) {
-	// nothing to do if recording is not enabled
-	if !recorder.RecordingEnabled() {
-		return
+	var orphaned []string
+
+	// recordings of tests that did not run are not loaded from storage yet
+	if err := recorder.loadAllStorage(); err != nil {
+		panic(err)
+	}
+
+	// tests declared in package can only be found if sources are available
+	_, _, pkgFsPath, err := getPkgInfo(skip+1, false)
+	if err == nil {
+		if declared, err := testdecl.Find(pkgFsPath); err == nil && len(declared) > 0 {
+			orphaned = findOrphaned(recorder, declared)
+		}
+	}
+
+	if recorder.RecordingEnabled() && *dropOrphanedFlag {
+		recorder.drop(orphaned)
+
+		for _, name := range orphaned {
+			fmt.Fprintf(os.Stderr, "testparrot: dropped orphaned recordings for test '%s'\n", name)
+		}
+
+		orphaned = nil
+	}
+
+	if recorder.RecordingEnabled() {
+		diffs, err := saveRecordings(recorder, newStorage(recorder, recorderVar, skip+1))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			osExit(1)
+			return
+		}
+
+		// inline snapshots are only written to test sources when recording,
+		// in check and review mode their diffs are reported
+		inlineDiffs, err := rewriteInlineSnapshots(recorder.snapshots(), !*checkFlag && !*reviewFlag)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, newErr(err))
+			osExit(1)
+			return
+		}
+
+		if *reviewFlag {
+			for _, diff := range inlineDiffs {
+				fmt.Fprint(os.Stderr, diff)
+			}
+		} else {
+			diffs = append(diffs, inlineDiffs...)
+		}
+
+		for _, diff := range diffs {
+			fmt.Fprint(os.Stderr, diff)
+		}
+
+		if len(diffs) > 0 {
+			fmt.Fprintln(os.Stderr, "testparrot: recordings are not up to date, re-record them with -testparrot.record")
+			osExit(1)
+		}
+	}
+
+	if *usageFileFlag != "" {
+		if err := writeUsage(recorder, *usageFileFlag); err != nil {
+			panic(newErr(err))
+		}
+	}
+
+	// recordings of orphaned tests are only reported as orphaned
+	unusedRecordings := recorder.Unused()
+	for _, name := range orphaned {
+		delete(unusedRecordings, name)
+	}
+
+	unused := reportUnused(unusedRecordings, os.Stderr)
+	unused += reportOrphaned(orphaned, os.Stderr)
+
+	if unused > 0 && *strictFlag {
+		fmt.Fprintln(os.Stderr, "testparrot: unused recordings found in strict mode")
+		osExit(1)
+	}
+}
+
+// runFilter returns value of -test.run flag
+func runFilter() string {
+	if f := flag.Lookup("test.run"); f != nil {
+		return f.Value.String()
+	}
+
+	return ""
+}
+
+// testFilter defines tests selected to run by -test.run, -test.bench,
+// -test.skip and -test.short flags
+type testFilter struct {
+	run   string
+	bench string
+	skip  string
+	short bool
+}
+
+// currentTestFilter returns filter of tests selected by flags of this run
+func currentTestFilter() testFilter {
+	filter := testFilter{run: runFilter()}
+
+	if f := flag.Lookup("test.bench"); f != nil {
+		filter.bench = f.Value.String()
+	}
+
+	if f := flag.Lookup("test.skip"); f != nil {
+		filter.skip = f.Value.String()
+	}
+
+	if f := flag.Lookup("test.short"); f != nil {
+		filter.short = f.Value.String() == "true"
+	}
+
+	return filter
+}
+
+// selects returns whether test with name ran, when test did not use
+// recorder. Like with go test, benchmarks run only when selected by
+// -test.bench, while tests, examples and seed corpus of fuzz tests are
+// selected by -test.run. In short mode tests could have been skipped, so
+// they are not selected.
+func (f testFilter) selects(name string) bool {
+	if f.short || (f.skip != "" && matchFilter(f.skip, name, false)) {
+		return false
+	}
+
+	if strings.HasPrefix(name, "Benchmark") {
+		return f.bench != "" && matchFilter(f.bench, name, true)
+	}
+
+	return matchFilter(f.run, name, true)
+}
+
+// matchFilter matches name of a test with a filter, like -test.run, where
+// every level of subtest is matched by its own regular expression separated
+// by slash. Partial match matches name with less levels than filter, like
+// top level test of matched subtest.
+func matchFilter(filter string, name string, partial bool) bool {
+	patterns := splitFilter(filter)
+	names := strings.Split(name, "/")
+
+	if !partial && len(names) < len(patterns) {
+		return false
+	}
+
+	for i, pattern := range patterns {
+		if i >= len(names) {
+			break
+		}
+
+		matched, err := regexp.MatchString(pattern, names[i])
+		if err != nil || !matched {
+			return false
+		}
+	}
+
+	return true
+}
+
+// splitFilter splits filter into regular expressions of subtest levels, like
+// go test does, slashes in brackets and parentheses do not separate levels
+func splitFilter(filter string) []string {
+	if filter == "" {
+		return nil
+	}
+
+	patterns := []string{}
+	depth, start := 0, 0
+	for i := 0; i < len(filter); i++ {
+		switch filter[i] {
+		case '[', '(':
+			depth++
+		case ']', ')':
+			depth--
+		case '\\':
+			i++
+		case '/':
+			if depth == 0 {
+				patterns = append(patterns, filter[start:i])
+				start = i + 1
+			}
+		}
+	}
+
+	return append(patterns, filter[start:])
+}
+
+// findOrphaned finds loaded tests that no longer exist, because their top
+// level test is not declared in the package. Subtests of declared tests are
+// never orphaned, as they could have been skipped, instead their recordings
+// are reported as unused.
+func findOrphaned(recorder *Recorder, declared map[string]string) []string {
+	orphaned := []string{}
+	for name := range recorder.recordings() {
+		if _, ok := declared[testdecl.TopLevel(name)]; !ok {
+			orphaned = append(orphaned, name)
+		}
+	}
+
+	sort.Strings(orphaned)
+
+	return orphaned
+}
+
+// reportOrphaned reports orphaned tests and returns their number
+func reportOrphaned(orphaned []string, out io.Writer) int {
+	for _, name := range orphaned {
+		fmt.Fprintf(out, "testparrot: orphaned recordings for test '%s'\n", name)
+	}
+
+	return len(orphaned)
+}
+
+// reportUnused reports recordings that were not used by tests that ran and
+// returns number of unused recordings
+func reportUnused(unused map[string][]Recording, out io.Writer) int {
+	names := make([]string, 0, len(unused))
+	for name := range unused {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	count := 0
+	for _, name := range names {
+		for _, recording := range unused[name] {
+			fmt.Fprintf(out, "testparrot: unused recording with key '%v' for test '%s'\n", recording.Key, name)
+			count++
+		}
+	}
+
+	return count
+}
+
+// newStorage returns storage recordings are saved to. Unless recorder is
+// configured with a storage, recordings are stored as generated go code.
+func newStorage(recorder *Recorder, recorderVar string, skip int) Storage {
+	if storage := recorder.Storage(); storage != nil {
+		if reviewer, ok := storage.(Reviewer); ok {
+			reviewer.SetReview(*reviewFlag)
+		}
+
+		return storage
 	}
 
 	// get package path and name, so we know where to put and name generated file
@@ -97,56 +391,82 @@ func afterTests(recorder *Recorder, recorderVar string, skip int) {
 	}
 
 	generator := NewGenerator(pkgPath, pkgName)
-	if *splitFilesFlag {
-		// group test names by filename
-		testNamesByFilename := map[string][]string{}
-		for testName, testFilename := range recorder.testFilenames {
-			testNamesByFilename[testFilename] = append(testNamesByFilename[testFilename], testName)
-		}
-
-		// for every filename generate recordings
-		for testFilename, fileTestNames := range testNamesByFilename {
-			genFilename := strings.TrimSuffix(testFilename, filepath.Ext(testFilename))
-			genFilename = strings.TrimSuffix(genFilename, "_test") + "_recording_test.go"
-			genFilePath := path.Join(dest, genFilename)
-
-			filter := func(testRecordings map[string][]Recording) map[string][]Recording {
-				result := map[string][]Recording{}
-
-				for testName, recordings := range testRecordings {
-					for _, fileTestName := range fileTestNames {
-						if fileTestName == testName {
-							result[testName] = recordings
-						}
-					}
-				}
-
-				return result
-			}
+	generator.SetBlobs(dest, *blobThresholdFlag, *blobGzipFlag)
 
-			opts := GenOptions{
-				RecorderVar: recorderVar,
-				Filter:      filter,
-			}
-			err = generator.GenerateToFile(recorder, opts, genFilePath)
-			if err != nil {
-				panic(newErr(err))
-			}
+	storage := NewGoStorage(recorder, generator, dest)
+	storage.SplitFiles = *splitFilesFlag
+	storage.RecorderVar = recorderVar
+	storage.SetReview(*reviewFlag)
+
+	if *filenameFlag != "" {
+		storage.Filename = *filenameFlag
+	}
+
+	return storage
+}
+
+// saveRecordings saves recordings of tests that recorded values and removes
+// recordings of dropped tests. In check mode recordings are not saved,
+// instead diffs of outdated recordings are returned. Check and review mode
+// fail for storages that do not support them.
+func saveRecordings(recorder *Recorder, storage Storage) ([]string, error) {
+	// review mode is enabled by newStorage
+	if _, ok := storage.(Reviewer); *reviewFlag && !ok {
+		return nil, newErr(fmt.Errorf("review mode is not supported by storage %T", storage))
+	}
+
+	checker, ok := storage.(Checker)
+	if *checkFlag {
+		if !ok {
+			return nil, newErr(fmt.Errorf("check mode is not supported by storage %T", storage))
 		}
-	} else {
-		var genFilePath string
-		if *filenameFlag == "" {
-			genFileName := fmt.Sprintf("%s_recording_test.go", pkgName)
-			genFilePath = path.Join(dest, genFileName)
-		} else {
-			genFilePath = path.Join(dest, *filenameFlag)
+
+		checker.SetCheck(true)
+	}
+
+	recordings := recorder.recordings()
+
+	// tests that were loaded, but no longer have recordings, were dropped
+	changed := recorder.recorded()
+	for name := range recorder.loadedFiles() {
+		if _, ok := recordings[name]; !ok {
+			changed[name] = true
+		}
+	}
+	for name, loaded := range recorder.storageFiles() {
+		if _, ok := recordings[name]; loaded && !ok {
+			changed[name] = true
 		}
+	}
 
-		opts := GenOptions{RecorderVar: recorderVar}
-		err = generator.GenerateToFile(recorder, opts, genFilePath)
+	names := make([]string, 0, len(changed))
+	for name := range changed {
+		names = append(names, name)
+	}
+	sort.Strings(names)
 
-		if err != nil {
-			panic(newErr(err))
+	for _, name := range names {
+		if err := storage.Save(name, recordings[name]); err != nil {
+			return nil, newErr(err)
 		}
 	}
+
+	if flusher, ok := storage.(Flusher); ok {
+		if err := flusher.Flush(); err != nil {
+			return nil, newErr(err)
+		}
+	}
+
+	if *checkFlag {
+		return checker.CheckDiffs(), nil
+	}
+
+	return nil, nil
+}
+
+// RecordingFilename returns name of generated recording file for a test file,
+// when recordings are split into multiple files
+func RecordingFilename(testFilename string) string {
+	genFilename := strings.TrimSuffix(testFilename, filepath.Ext(testFilename))
+	return strings.TrimSuffix(genFilename, "_test") + recordingFileSuffix
 }
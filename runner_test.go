@@ -1,6 +1,7 @@
 package testparrot
 
 import (
+	"bytes"
 	"flag"
 	"io/ioutil"
 	"os"
@@ -39,10 +40,282 @@ func TestBeforeTests(t *testing.T) {
 
 		BeforeTests(recorder)
 
-		require.Empty(t, recorder.allRecordings)
+		// recordings are kept, so only tests that run are re-recorded
+		require.Contains(t, recorder.allRecordings, "test")
+		require.True(t, recorder.RecordingEnabled())
+	})
+
+	t.Run("record missing", func(t *testing.T) {
+		recorder := NewRecorder()
+
+		flag.Set("testparrot.mode", "missing")
+		defer flag.Set("testparrot.mode", "replay") // reset flag value
+
+		BeforeTests(recorder)
+
+		require.Equal(t, ModeMissing, recorder.Mode())
+	})
+}
+
+func TestReportUnused(t *testing.T) {
+	recorder := NewRecorder()
+	recorder.Load("test1", []Recording{{"key1", "value1"}, {"key2", "value2"}})
+	recorder.Load("test2", []Recording{{0, "value1"}})
+	recorder.markUsed("test1", "key1")
+	recorder.markUsed("test2", 1)
+
+	out := &bytes.Buffer{}
+	require.Equal(t, 2, reportUnused(recorder.unused(testFilter{run: "^$"}), out))
+	require.Equal(t,
+		"testparrot: unused recording with key 'key2' for test 'test1'\n"+
+			"testparrot: unused recording with key '0' for test 'test2'\n",
+		out.String())
+}
+
+func TestAfterTestsStrict(t *testing.T) {
+	defineTestparrotFlags()
+
+	flag.Set("testparrot.strict", "true")
+	defer flag.Set("testparrot.strict", "false")
+
+	exitCode := -1
+	osExit = func(code int) { exitCode = code }
+	defer func() { osExit = os.Exit }()
+
+	t.Run("no unused recordings", func(t *testing.T) {
+		recorder := NewRecorder()
+		recorder.Load(t.Name(), []Recording{{"key", "value"}})
+		recorder.markUsed(t.Name(), "key")
+
+		AfterTests(recorder, "recorder")
+		require.Equal(t, -1, exitCode)
+	})
+
+	t.Run("unused recordings", func(t *testing.T) {
+		recorder := NewRecorder()
+		recorder.Load(t.Name(), []Recording{{"key1", "value"}, {"key2", "value"}})
+		recorder.markUsed(t.Name(), "key1")
+
+		AfterTests(recorder, "recorder")
+		require.Equal(t, 1, exitCode)
+	})
+
+	t.Run("orphaned recordings", func(t *testing.T) {
+		exitCode = -1
+
+		recorder := NewRecorder()
+		recorder.Load("TestRemoved", []Recording{{"key", "value"}})
+
+		AfterTests(recorder, "recorder")
+		require.Equal(t, 1, exitCode)
+	})
+}
+
+func TestAfterTestsCheck(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	defineTestparrotFlags()
+
+	flag.Set("testparrot.dest", tmpDir)
+	flag.Set("testparrot.filename", "gen.go")
+	flag.Set("testparrot.pkgpath", "my/go-pkg")
+	flag.Set("testparrot.pkgname", "pkg")
+	defer flag.Set("testparrot.dest", "")
+	defer flag.Set("testparrot.filename", "")
+	defer flag.Set("testparrot.pkgpath", "")
+	defer flag.Set("testparrot.pkgname", "")
+
+	exitCode := -1
+	osExit = func(code int) { exitCode = code }
+	defer func() { osExit = os.Exit }()
+
+	genPath := path.Join(tmpDir, "gen.go")
+
+	recorder := NewRecorder()
+	recorder.Load(t.Name(), []Recording{{"key", "value"}})
+	recorder.EnableRecording(true)
+	AfterTests(recorder, "recorder")
+
+	flag.Set("testparrot.check", "true")
+	defer flag.Set("testparrot.check", "false")
+
+	t.Run("check mode enables recording", func(t *testing.T) {
+		recorder := NewRecorder()
+		BeforeTests(recorder)
+		require.Equal(t, ModeRecord, recorder.Mode())
+	})
+
+	t.Run("up to date", func(t *testing.T) {
+		AfterTests(recorder, "recorder")
+		require.Equal(t, -1, exitCode)
+	})
+
+	t.Run("outdated", func(t *testing.T) {
+		recorder := NewRecorder()
+		recorder.Load("TestAfterTestsCheck", []Recording{{"key", "changed"}})
+		recorder.EnableRecording(true)
+
+		AfterTests(recorder, "recorder")
+		require.Equal(t, 1, exitCode)
+
+		// file is not written in check mode
+		contents, err := ioutil.ReadFile(genPath)
+		if err != nil {
+			panic(err)
+		}
+
+		require.NotContains(t, string(contents), "changed")
+	})
+}
+
+func TestAfterTestsInlineError(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	defineTestparrotFlags()
+
+	flag.Set("testparrot.dest", tmpDir)
+	flag.Set("testparrot.filename", "gen.go")
+	flag.Set("testparrot.pkgpath", "my/go-pkg")
+	flag.Set("testparrot.pkgname", "pkg")
+	defer flag.Set("testparrot.dest", "")
+	defer flag.Set("testparrot.filename", "")
+	defer flag.Set("testparrot.pkgpath", "")
+	defer flag.Set("testparrot.pkgname", "")
+
+	exitCode := -1
+	osExit = func(code int) { exitCode = code }
+	defer func() { osExit = os.Exit }()
+
+	recorder := NewRecorder()
+	recorder.EnableRecording(true)
+	require.NoError(t, recorder.addSnapshot(inlineSnapshot{
+		filename: path.Join(tmpDir, "missing_test.go"), line: 1, test: t.Name(), value: "value",
+	}))
+
+	AfterTests(recorder, "recorder")
+	require.Equal(t, 1, exitCode)
+}
+
+func TestAfterTestsReview(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	defineTestparrotFlags()
+
+	flag.Set("testparrot.dest", tmpDir)
+	flag.Set("testparrot.filename", "gen.go")
+	flag.Set("testparrot.pkgpath", "my/go-pkg")
+	flag.Set("testparrot.pkgname", "pkg")
+	defer flag.Set("testparrot.dest", "")
+	defer flag.Set("testparrot.filename", "")
+	defer flag.Set("testparrot.pkgpath", "")
+	defer flag.Set("testparrot.pkgname", "")
+
+	genPath := path.Join(tmpDir, "gen.go")
+	pendingPath := genPath + PendingFileSuffix
+
+	recorder := NewRecorder()
+	recorder.Load(t.Name(), []Recording{{"key", "value"}})
+	recorder.EnableRecording(true)
+	AfterTests(recorder, "recorder")
+
+	flag.Set("testparrot.review", "true")
+	defer flag.Set("testparrot.review", "false")
+
+	t.Run("changed", func(t *testing.T) {
+		recorder := NewRecorder()
+		recorder.Load("TestAfterTestsReview", []Recording{{"key", "changed"}})
+		recorder.EnableRecording(true)
+
+		AfterTests(recorder, "recorder")
+
+		contents, err := ioutil.ReadFile(pendingPath)
+		require.NoError(t, err)
+		require.Contains(t, string(contents), "changed")
+
+		// generated file is not written in review mode
+		contents, err = ioutil.ReadFile(genPath)
+		require.NoError(t, err)
+		require.NotContains(t, string(contents), "changed")
+	})
+
+	t.Run("up to date", func(t *testing.T) {
+		AfterTests(recorder, "recorder")
+		require.NoFileExists(t, pendingPath)
 	})
 }
 
+func TestMatchFilter(t *testing.T) {
+	tests := []struct {
+		filter   string
+		name     string
+		partial  bool
+		expected bool
+	}{
+		{"", "TestA/sub", true, true},
+		{"TestA", "TestA/sub", true, true},
+		{"TestB", "TestA", true, false},
+		{"TestA/sub", "TestA", true, true},
+		{"TestA/sub", "TestA", false, false},
+		{"TestA/sub", "TestA/sub2", false, true},
+		{"TestA/other", "TestA/sub", true, false},
+		{"Test[/]A", "Test/A", true, false},
+	}
+
+	for _, test := range tests {
+		require.Equal(t, test.expected, matchFilter(test.filter, test.name, test.partial),
+			"filter %q, name %q", test.filter, test.name)
+	}
+}
+
+func TestFindOrphaned(t *testing.T) {
+	recorder := NewRecorder()
+	recorder.Load("TestRemoved", []Recording{{"key", "value"}})
+	recorder.Load("TestRemoved/sub", []Recording{{"key", "value"}})
+	recorder.Load("TestRan", []Recording{{"key", "value"}})
+	recorder.Load("TestRan/sub", []Recording{{"key", "value"}})
+	recorder.Load("TestRan/removed", []Recording{{"key", "value"}})
+	recorder.Load("TestNotRan", []Recording{{"key", "value"}})
+	recorder.Load("TestNotRan/sub", []Recording{{"key", "value"}})
+	recorder.markUsed("TestRan/sub", "key")
+
+	declared := map[string]string{"TestRan": "ran_test.go", "TestNotRan": "ran_test.go"}
+
+	// subtests of declared tests could have been skipped
+	require.Equal(t, []string{"TestRemoved", "TestRemoved/sub"}, findOrphaned(recorder, declared))
+}
+
+func TestAfterTestsDropOrphaned(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	defineTestparrotFlags()
+
+	flag.Set("testparrot.dest", tmpDir)
+	flag.Set("testparrot.filename", "gen.go")
+	flag.Set("testparrot.pkgpath", "my/go-pkg")
+	flag.Set("testparrot.pkgname", "pkg")
+	flag.Set("testparrot.droporphaned", "true")
+	defer flag.Set("testparrot.dest", "")
+	defer flag.Set("testparrot.filename", "")
+	defer flag.Set("testparrot.pkgpath", "")
+	defer flag.Set("testparrot.pkgname", "")
+	defer flag.Set("testparrot.droporphaned", "false")
+
+	recorder := NewRecorder()
+	recorder.Load("TestRemoved", []Recording{{"key", "value"}})
+	recorder.Load(t.Name(), []Recording{{"key", "value"}})
+	recorder.SetMode(ModeMissing)
+
+	AfterTests(recorder, "recorder")
+
+	contents, err := ioutil.ReadFile(path.Join(tmpDir, "gen.go"))
+	if err != nil {
+		panic(err)
+	}
+
+	require.Contains(t, string(contents), t.Name())
+	require.NotContains(t, string(contents), "TestRemoved")
+}
+
 func TestAfterTests(t *testing.T) {
 	tmpDir := t.TempDir()
 
@@ -101,6 +374,9 @@ func TestAfterTests(t *testing.T) {
 		recorder.testFilenames["test2"] = "file1_test.go"
 		recorder.testFilenames["test3"] = "file2_test.go"
 		recorder.testFilenames["test4"] = "file2_test.go"
+		for _, name := range []string{"test1", "test2", "test3", "test4"} {
+			recorder.recordedTests[name] = true
+		}
 		recorder.EnableRecording(true)
 
 		flag.Set("testparrot.pkgpath", "my/go-pkg")
@@ -136,4 +412,41 @@ func TestAfterTests(t *testing.T) {
 		require.NotContains(t, string(contents), "test1")
 		require.NotContains(t, string(contents), "test2")
 	})
+
+	t.Run("partial split files", func(t *testing.T) {
+		recorder := NewRecorder()
+		recorder.allRecordings["test1"] = []Recording{{"key1", "old"}}
+		recorder.allRecordings["test2"] = []Recording{{"key1", "value1"}}
+		recorder.allRecordings["test3"] = []Recording{{"key2", "value2"}}
+		recorder.loadedFilenames["test1"] = "file3_recording_test.go"
+		recorder.loadedFilenames["test2"] = "file3_recording_test.go"
+		recorder.loadedFilenames["test3"] = "file4_recording_test.go"
+		recorder.EnableRecording(true)
+
+		// only test1 runs and records a new value
+		_, err := recorder.record("test1", "key1", "new")
+		require.NoError(t, err)
+		recorder.testFilenames["test1"] = "file3_test.go"
+
+		flag.Set("testparrot.pkgpath", "my/go-pkg")
+		defer flag.Set("testparrot.pkgpath", "")
+		flag.Set("testparrot.pkgname", "pkg")
+		defer flag.Set("testparrot.pkgname", "")
+		flag.Set("testparrot.splitfiles", "true")
+		defer flag.Set("testparrot.splitfiles", "")
+
+		AfterTests(recorder, "recorder")
+
+		contents, err := ioutil.ReadFile(path.Join(tmpDir, "file3_recording_test.go"))
+		if err != nil {
+			panic(err)
+		}
+
+		require.Contains(t, string(contents), "new")
+		require.NotContains(t, string(contents), "old")
+		require.Contains(t, string(contents), "test2")
+
+		// files without tests that ran are left untouched
+		require.NoFileExists(t, path.Join(tmpDir, "file4_recording_test.go"))
+	})
 }
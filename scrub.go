@@ -0,0 +1,360 @@
+package testparrot
+
+import (
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// Redacted defines placeholder that replaces scrubbed values
+const Redacted = "REDACTED"
+
+// scrubber defines a rule for replacing sensitive values in recordings
+type scrubber struct {
+	// re defines regular expression, whose matches in strings are replaced
+	// with replacement
+	re *regexp.Regexp
+
+	// replacement defines replacement of regular expression matches
+	replacement string
+
+	// path defines path of struct field, whose value is redacted
+	path string
+
+	// mapKey defines key of map entries, whose values are redacted
+	mapKey string
+}
+
+// ScrubRegexp method replaces matches of regular expression in all recorded
+// strings with replacement, which can reference submatches like in
+// regexp.ReplaceAllString. Replacement should not match regular expression
+// again, so scrubbed values stay unchanged when they are scrubbed again.
+func (r *Recorder) ScrubRegexp(re *regexp.Regexp, replacement string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.scrubbers = append(r.scrubbers, scrubber{re: re, replacement: replacement})
+}
+
+// ScrubField method redacts values of struct field with path, like
+// .Auth.Token. Elements of slices, arrays and maps are not part of the path,
+// so .Users.Token redacts tokens of all users in a slice.
+func (r *Recorder) ScrubField(path string) {
+	if !strings.HasPrefix(path, ".") {
+		path = "." + path
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.scrubbers = append(r.scrubbers, scrubber{path: path})
+}
+
+// ScrubMapKey method redacts values of map entries with key
+func (r *Recorder) ScrubMapKey(key string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.scrubbers = append(r.scrubbers, scrubber{mapKey: key})
+}
+
+// scrubValue returns a copy of value with sensitive values replaced. Strings
+// are redacted with Redacted placeholder, while values of other types are
+// replaced with zero values. Struct fields tagged with `testparrot:"redact"`
+// are redacted too, and fields tagged with `testparrot:"-"` are set to zero
+// values. Values are scrubbed both before they are recorded and before actual
+// values are compared with recordings, so redacted values equal any actual
+// value.
+func scrubValue(scrubbers []scrubber, constructors map[reflect.Type]constructor, value interface{}) interface{} {
+	if value == nil {
+		return value
+	}
+
+	// without scrubbers only tagged fields are scrubbed, so values that
+	// cannot hold them are not copied
+	if len(scrubbers) == 0 && !mayHoldTags(reflect.TypeOf(value), constructors, map[reflect.Type]bool{}) {
+		return value
+	}
+
+	hasRegexps := false
+	for _, s := range scrubbers {
+		if s.re != nil {
+			hasRegexps = true
+		}
+	}
+
+	rw := newRewriter(constructors, func(path string, field *reflect.StructField, mapKey reflect.Value, value reflect.Value) (reflect.Value, bool) {
+		if field != nil {
+			// invalid options are reported when values are generated or
+			// compared
+			tag, _ := parseTag(*field)
+
+			switch {
+			case tag.omit:
+				return reflect.Zero(value.Type()), true
+			case tag.redact:
+				return placeholder(value.Type(), Redacted), true
+			}
+		}
+
+		for _, s := range scrubbers {
+			switch {
+			case s.path != "" && s.path == path:
+				return placeholder(value.Type(), Redacted), true
+			case s.mapKey != "" && mapKey.IsValid() && mapKey.Kind() == reflect.String && mapKey.String() == s.mapKey:
+				return placeholder(value.Type(), Redacted), true
+			}
+		}
+
+		if value.Kind() != reflect.String || len(scrubbers) == 0 {
+			return value, false
+		}
+
+		str := value.String()
+		for _, s := range scrubbers {
+			if s.re != nil {
+				str = s.re.ReplaceAllString(str, s.replacement)
+			}
+		}
+
+		return reflect.ValueOf(str).Convert(value.Type()), true
+	})
+
+	// only strings are scrubbed by regular expressions
+	rw.rewritesBasic = func(typ reflect.Type) bool {
+		return hasRegexps && typ.Kind() == reflect.String
+	}
+
+	return rw.rewrite("", nil, reflect.Value{}, reflect.ValueOf(value)).Interface()
+}
+
+// mayHoldTags returns whether values of type may hold struct fields with
+// testparrot tags that omit or redact them. Values in interfaces and values
+// created by constructors may hold any fields.
+func mayHoldTags(typ reflect.Type, constructors map[reflect.Type]constructor, seen map[reflect.Type]bool) bool {
+	if seen[typ] {
+		return false
+	}
+	seen[typ] = true
+
+	if _, ok := constructors[typ]; ok {
+		return true
+	}
+
+	switch typ.Kind() {
+	case reflect.Interface:
+		return true
+	case reflect.Ptr, reflect.Slice, reflect.Array:
+		return mayHoldTags(typ.Elem(), constructors, seen)
+	case reflect.Map:
+		return mayHoldTags(typ.Key(), constructors, seen) || mayHoldTags(typ.Elem(), constructors, seen)
+	case reflect.Struct:
+		for i := 0; i < typ.NumField(); i++ {
+			field := typ.Field(i)
+			if field.PkgPath != "" {
+				continue
+			}
+
+			if tag, _ := parseTag(field); tag.omit || tag.redact {
+				return true
+			}
+
+			if mayHoldTags(field.Type, constructors, seen) {
+				return true
+			}
+		}
+
+		return false
+	default:
+		return false
+	}
+}
+
+// placeholder returns placeholder value of type. Placeholder string is used
+// for strings and interfaces, while other types are replaced with zero values.
+func placeholder(typ reflect.Type, str string) reflect.Value {
+	strVal := reflect.ValueOf(str)
+
+	switch {
+	case typ.Kind() == reflect.String:
+		return strVal.Convert(typ)
+	case typ.Kind() == reflect.Interface && strVal.Type().AssignableTo(typ):
+		result := reflect.New(typ).Elem()
+		result.Set(strVal)
+		return result
+	default:
+		return reflect.Zero(typ)
+	}
+}
+
+// rewriteFunc returns rewritten value at field path and whether value was
+// rewritten. Field is set only for values of struct fields and map key is
+// valid only for values of map entries.
+type rewriteFunc func(path string, field *reflect.StructField, mapKey reflect.Value, value reflect.Value) (reflect.Value, bool)
+
+// rewriter deep copies values, while rewriting values selected by rewrite
+// function. Values in unexported struct fields are copied as they are, unless
+// their type has a registered constructor, in which case constructor
+// arguments are rewritten and value is created again by constructor.
+type rewriter struct {
+	f rewriteFunc
+
+	// constructors defines registered constructors of types with unexported
+	// fields
+	constructors map[reflect.Type]constructor
+
+	// rewritesBasic returns whether rewrite function can rewrite values of
+	// basic type, otherwise slices and arrays of such values are copied
+	// as a whole. Nil function rewrites values of all basic types.
+	rewritesBasic func(typ reflect.Type) bool
+
+	// copies defines copies of pointers, so shared and cyclic pointers are
+	// copied only once
+	copies map[visit]reflect.Value
+}
+
+func newRewriter(constructors map[reflect.Type]constructor, f rewriteFunc) *rewriter {
+	return &rewriter{f: f, constructors: constructors, copies: map[visit]reflect.Value{}}
+}
+
+// rewrite returns rewritten copy of value with path
+func (rw *rewriter) rewrite(path string, field *reflect.StructField, mapKey reflect.Value, value reflect.Value) reflect.Value {
+	if !value.IsValid() {
+		return value
+	}
+
+	if rewritten, ok := rw.f(path, field, mapKey, value); ok {
+		return rewritten
+	}
+
+	if c, ok := rw.constructors[value.Type()]; ok {
+		if constructed, ok := rw.construct(path, c, value); ok {
+			return constructed
+		}
+	}
+
+	switch value.Kind() {
+	case reflect.Ptr:
+		if value.IsNil() {
+			return value
+		}
+
+		v := visit{value.Pointer(), 0, value.Type()}
+		if ptr, ok := rw.copies[v]; ok {
+			return ptr
+		}
+
+		ptr := reflect.New(value.Type().Elem())
+		rw.copies[v] = ptr
+
+		ptr.Elem().Set(rw.rewrite(path, nil, reflect.Value{}, value.Elem()))
+
+		return ptr
+	case reflect.Interface:
+		if value.IsNil() {
+			return value
+		}
+
+		result := reflect.New(value.Type()).Elem()
+		result.Set(rw.rewrite(path, nil, reflect.Value{}, value.Elem()))
+
+		return result
+	case reflect.Struct:
+		result := reflect.New(value.Type()).Elem()
+		result.Set(value)
+
+		for i := 0; i < value.NumField(); i++ {
+			field := value.Type().Field(i)
+			if field.PkgPath != "" {
+				continue
+			}
+
+			result.Field(i).Set(rw.rewrite(path+"."+field.Name, &field, reflect.Value{}, value.Field(i)))
+		}
+
+		return result
+	case reflect.Slice:
+		if value.IsNil() {
+			return value
+		}
+
+		result := reflect.MakeSlice(value.Type(), value.Len(), value.Len())
+		if !rw.rewritesElems(value.Type().Elem()) {
+			reflect.Copy(result, value)
+			return result
+		}
+
+		for i := 0; i < value.Len(); i++ {
+			result.Index(i).Set(rw.rewrite(path, nil, reflect.Value{}, value.Index(i)))
+		}
+
+		return result
+	case reflect.Array:
+		result := reflect.New(value.Type()).Elem()
+		if !rw.rewritesElems(value.Type().Elem()) {
+			result.Set(value)
+			return result
+		}
+
+		for i := 0; i < value.Len(); i++ {
+			result.Index(i).Set(rw.rewrite(path, nil, reflect.Value{}, value.Index(i)))
+		}
+
+		return result
+	case reflect.Map:
+		if value.IsNil() {
+			return value
+		}
+
+		result := reflect.MakeMapWithSize(value.Type(), value.Len())
+		iter := value.MapRange()
+		for iter.Next() {
+			result.SetMapIndex(iter.Key(), rw.rewrite(path, nil, iter.Key(), iter.Value()))
+		}
+
+		return result
+	default:
+		return value
+	}
+}
+
+// rewritesElems returns whether elements of slices and arrays with element
+// type can be rewritten
+func (rw *rewriter) rewritesElems(typ reflect.Type) bool {
+	if !isBasicKind(typ.Kind()) || rw.rewritesBasic == nil {
+		return true
+	}
+
+	if _, ok := rw.constructors[typ]; ok {
+		return true
+	}
+
+	return rw.rewritesBasic(typ)
+}
+
+// construct returns value created by constructor from rewritten constructor
+// arguments of value. Value is not rewritten if constructor arguments are
+// invalid, which is reported when value is generated.
+func (rw *rewriter) construct(path string, c constructor, value reflect.Value) (reflect.Value, bool) {
+	args, err := c.callArgs(value)
+	if err != nil {
+		return value, false
+	}
+
+	argVals := make([]reflect.Value, 0, len(args))
+	for i, arg := range args {
+		argVal := rw.rewrite(path, nil, reflect.Value{}, reflect.ValueOf(arg))
+
+		typ := c.argType(i)
+		switch {
+		case !argVal.IsValid():
+			argVal = reflect.Zero(typ)
+		case !argVal.Type().AssignableTo(typ):
+			return value, false
+		}
+
+		argVals = append(argVals, argVal)
+	}
+
+	return c.fn.Call(argVals)[0], true
+}
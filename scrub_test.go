@@ -0,0 +1,188 @@
+package testparrot
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testAuth struct {
+	User  string
+	Token string
+}
+
+type testResponse struct {
+	Auth    *testAuth
+	Users   []testAuth
+	Headers map[string]string
+	Body    interface{}
+	Code    int
+}
+
+func TestScrubValue(t *testing.T) {
+	response := testResponse{
+		Auth:    &testAuth{User: "user", Token: "secret"},
+		Users:   []testAuth{{User: "user1", Token: "secret1"}},
+		Headers: map[string]string{"Authorization": "Bearer secret", "Accept": "*/*"},
+		Body:    map[string]interface{}{"email": "user@example.com", "apiKey": "key"},
+		Code:    200,
+	}
+
+	t.Run("no scrubbers", func(t *testing.T) {
+		require.Equal(t, response, scrubValue(nil, nil, response))
+	})
+
+	t.Run("field path", func(t *testing.T) {
+		scrubbed := scrubValue([]scrubber{{path: ".Auth.Token"}, {path: ".Users.Token"}, {path: ".Code"}}, nil, response)
+		require.Equal(t, testResponse{
+			Auth:    &testAuth{User: "user", Token: Redacted},
+			Users:   []testAuth{{User: "user1", Token: Redacted}},
+			Headers: response.Headers,
+			Body:    response.Body,
+		}, scrubbed)
+
+		// original value is not modified
+		require.Equal(t, "secret", response.Auth.Token)
+		require.Equal(t, "secret1", response.Users[0].Token)
+	})
+
+	t.Run("map key", func(t *testing.T) {
+		scrubbed := scrubValue([]scrubber{{mapKey: "Authorization"}, {mapKey: "apiKey"}}, nil, response)
+		require.Equal(t, map[string]string{"Authorization": Redacted, "Accept": "*/*"}, scrubbed.(testResponse).Headers)
+		require.Equal(t, map[string]interface{}{"email": "user@example.com", "apiKey": Redacted}, scrubbed.(testResponse).Body)
+		require.Equal(t, "Bearer secret", response.Headers["Authorization"])
+	})
+
+	t.Run("regexp", func(t *testing.T) {
+		scrubbed := scrubValue([]scrubber{
+			{re: regexp.MustCompile(`[a-z]+@example\.com`), replacement: "email@example.com"},
+			{re: regexp.MustCompile(`Bearer \w+`), replacement: "Bearer " + Redacted},
+		}, nil, response)
+		require.Equal(t, map[string]string{"Authorization": "Bearer REDACTED", "Accept": "*/*"}, scrubbed.(testResponse).Headers)
+		require.Equal(t, map[string]interface{}{"email": "email@example.com", "apiKey": "key"}, scrubbed.(testResponse).Body)
+	})
+
+	t.Run("struct tags", func(t *testing.T) {
+		type credentials struct {
+			User     string
+			Password string `testparrot:"-"`
+			Token    string `testparrot:"redact"`
+			Expires  int    `testparrot:"redact"`
+		}
+
+		scrubbed := scrubValue(nil, nil, credentials{User: "user", Password: "password", Token: "token", Expires: 10})
+		require.Equal(t, credentials{User: "user", Token: Redacted}, scrubbed)
+	})
+
+	t.Run("constructor args", func(t *testing.T) {
+		recorder := NewRecorder()
+		recorder.RegisterConstructor(newTestMoney, testMoneyArgs)
+
+		type wallet struct {
+			Owner string
+			Money *testMoney
+		}
+
+		money := newTestMoney(100, "secret")
+		scrubbed := scrubValue([]scrubber{{re: regexp.MustCompile(`secret`), replacement: Redacted}},
+			recorder.getConstructors(), wallet{Owner: "secret", Money: &money})
+		require.Equal(t, wallet{Owner: Redacted, Money: &testMoney{100, Redacted}}, scrubbed)
+		require.Equal(t, "secret", money.currency)
+	})
+
+	t.Run("basic slices", func(t *testing.T) {
+		type blob struct {
+			Data  []byte
+			Lines []string
+			Token string `testparrot:"redact"`
+		}
+
+		value := blob{Data: []byte("secret"), Lines: []string{"secret"}, Token: "token"}
+		scrubbed := scrubValue([]scrubber{{re: regexp.MustCompile(`secret`), replacement: Redacted}}, nil, value).(blob)
+		require.Equal(t, blob{Data: []byte("secret"), Lines: []string{Redacted}, Token: Redacted}, scrubbed)
+
+		// byte slices are copied, not shared
+		scrubbed.Data[0] = 'S'
+		require.Equal(t, []byte("secret"), value.Data)
+	})
+
+	t.Run("values without tags", func(t *testing.T) {
+		value := []byte("secret")
+		require.Equal(t, &value[0], &scrubValue(nil, nil, value).([]byte)[0])
+	})
+
+	t.Run("cyclic pointers", func(t *testing.T) {
+		value := &testStruct{V1: "secret"}
+		value.V3 = value
+
+		scrubbed := scrubValue([]scrubber{{path: ".V1"}}, nil, value).(*testStruct)
+		require.Equal(t, Redacted, scrubbed.V1)
+		require.Same(t, scrubbed, scrubbed.V3)
+	})
+}
+
+func BenchmarkScrubValue(b *testing.B) {
+	scrubbers := []scrubber{{path: ".Auth.Token"}}
+	value := testResponse{Body: make([]byte, 8<<20)}
+
+	for i := 0; i < b.N; i++ {
+		scrubValue(scrubbers, nil, value)
+	}
+}
+
+func TestRecorderScrub(t *testing.T) {
+	newScrubRecorder := func() *Recorder {
+		recorder := NewRecorder()
+		recorder.ScrubField("Auth.Token")
+		recorder.ScrubMapKey("Authorization")
+		recorder.ScrubRegexp(regexp.MustCompile(`[a-z]+@example\.com`), "email@example.com")
+		return recorder
+	}
+
+	response := testResponse{
+		Auth:    &testAuth{User: "user@example.com", Token: "secret"},
+		Headers: map[string]string{"Authorization": "Bearer secret"},
+	}
+
+	scrubbed := testResponse{
+		Auth:    &testAuth{User: "email@example.com", Token: Redacted},
+		Headers: map[string]string{"Authorization": Redacted},
+	}
+
+	t.Run("recording enabled", func(t *testing.T) {
+		recorder := newScrubRecorder()
+		recorder.EnableRecording(true)
+
+		require.Equal(t, response, recorder.Record(t, "key", response))
+		require.Equal(t, []Recording{{"key", scrubbed}}, recorder.allRecordings[t.Name()])
+	})
+
+	t.Run("expect treats redacted values as wildcards", func(t *testing.T) {
+		recorder := newScrubRecorder()
+		recorder.Load(t.Name(), []Recording{{"key", scrubbed}})
+
+		other := testResponse{
+			Auth:    &testAuth{User: "other@example.com", Token: "other"},
+			Headers: map[string]string{"Authorization": "Bearer other"},
+		}
+
+		require.True(t, recorder.Expect(t, "key", other))
+	})
+
+	t.Run("expect reports differences of other values", func(t *testing.T) {
+		recorder := newScrubRecorder()
+		recorder.Load(t.Name(), []Recording{{"key", scrubbed}})
+
+		ft := &fakeTB{TB: t}
+		require.False(t, recorder.Expect(ft, "key", testResponse{
+			Auth:    &testAuth{User: "user@example.com", Token: "other"},
+			Headers: map[string]string{"Authorization": "Bearer other"},
+			Code:    500,
+		}))
+		require.Equal(t, []string{
+			"testparrot: value differs from recording with key 'key' for test '" + t.Name() + "':\n" +
+				"\t.Code: recorded 0, actual 500",
+		}, ft.failures)
+	})
+}
@@ -3,7 +3,7 @@
 
 pkgs.mkShell {
   buildInputs = with pkgs; [
-    go_1_15
+    go_1_18
     gopls
     delve
     go-outline
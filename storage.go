@@ -0,0 +1,302 @@
+package testparrot
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"sort"
+
+	"github.com/xtruder/go-testparrot/internal/recfile"
+)
+
+// Storage loads and saves recordings of tests. Recorder loads recordings of
+// a test from storage the first time test uses recorder, and after tests
+// finish, recordings of tests that changed are saved.
+type Storage interface {
+	// Load loads recordings of a test, nil recordings are returned if test
+	// has no stored recordings
+	Load(name string) ([]Recording, error)
+
+	// Save saves recordings of a test, stored recordings of a test are
+	// removed if recordings are empty
+	Save(name string, recordings []Recording) error
+
+	// List returns names of all tests with stored recordings
+	List() ([]string, error)
+}
+
+// Flusher is implemented by storages which need to write saved recordings
+// after all tests are saved
+type Flusher interface {
+	// Flush writes saved recordings
+	Flush() error
+}
+
+// LossFinder is implemented by storages that cannot store every value
+// faithfully, so recorder fails right away when a value would not replay
+// equal. Recordings stored as generated go code are checked by Generator.
+type LossFinder interface {
+	// Losses returns parts of value that would not equal recorded value
+	// after value is stored and loaded
+	Losses(value interface{}) []Loss
+}
+
+// Checker is implemented by storages that support check mode, in which
+// saved recordings are only compared with stored recordings, instead of
+// replacing them
+type Checker interface {
+	// SetCheck enables or disables check mode
+	SetCheck(check bool)
+
+	// CheckDiffs returns diffs of outdated stored recordings
+	CheckDiffs() []string
+}
+
+// Reviewer is implemented by storages that support review mode, in which
+// changed recordings are written for review with testparrot review command,
+// instead of replacing stored recordings
+type Reviewer interface {
+	// SetReview enables or disables review mode
+	SetReview(review bool)
+}
+
+// GoStorage stores recordings as generated go code. Generated files call Load
+// on recorder in their init functions, so recordings are already loaded when
+// tests start and Load method of storage returns no recordings. Saved
+// recordings are written to generated files on Flush.
+type GoStorage struct {
+	// Generator defines generator of go code
+	Generator *Generator
+
+	// Dir defines directory where generated files are written
+	Dir string
+
+	// Filename defines name of generated file, if recordings are not split
+	// into multiple files
+	Filename string
+
+	// SplitFiles defines whether recordings are split into a generated file
+	// per test file
+	SplitFiles bool
+
+	// RecorderVar defines name of recorder variable in generated code, if
+	// recorder is not global recorder
+	RecorderVar string
+
+	// recorder defines recorder whose recordings are generated
+	recorder *Recorder
+
+	// saved defines saved recordings, which replace recordings of recorder
+	saved map[string][]Recording
+
+	// check defines whether generated files are compared with recordings,
+	// instead of being written
+	check bool
+
+	// review defines whether changed recordings are written to pending
+	// files for review
+	review bool
+
+	// diffs defines diffs of outdated generated files in check mode
+	diffs []string
+}
+
+// NewGoStorage creates a new GoStorage, which generates code with recordings
+// of recorder into dir
+func NewGoStorage(recorder *Recorder, generator *Generator, dir string) *GoStorage {
+	return &GoStorage{
+		Generator: generator,
+		Dir:       dir,
+		Filename:  generator.pkgName + recordingFileSuffix,
+		recorder:  recorder,
+		saved:     map[string][]Recording{},
+	}
+}
+
+// Load method returns no recordings, as recordings in generated files are
+// loaded by their init functions
+func (s *GoStorage) Load(name string) ([]Recording, error) {
+	return nil, nil
+}
+
+// Save method saves recordings of a test, which are written on Flush
+func (s *GoStorage) Save(name string, recordings []Recording) error {
+	s.saved[name] = recordings
+	return nil
+}
+
+// List method returns names of tests in generated files in storage directory
+func (s *GoStorage) List() ([]string, error) {
+	paths, err := recfile.Find(s.Dir)
+	if err != nil {
+		return nil, err
+	}
+
+	names := []string{}
+	for _, path := range paths {
+		file, err := recfile.ParseFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, test := range file.Tests {
+			names = append(names, test.Name)
+		}
+	}
+
+	sort.Strings(names)
+
+	return names, nil
+}
+
+// SetCheck method enables check mode, in which generated files are only
+// compared with recordings
+func (s *GoStorage) SetCheck(check bool) {
+	s.check = check
+}
+
+// CheckDiffs method returns diffs of outdated generated files in check mode
+func (s *GoStorage) CheckDiffs() []string {
+	return s.diffs
+}
+
+// SetReview method enables review mode, in which changed recordings are
+// written to pending files next to generated files
+func (s *GoStorage) SetReview(review bool) {
+	s.review = review
+}
+
+// recordings returns recordings of recorder, with saved recordings of tests
+// replacing recordings in recorder
+func (s *GoStorage) recordings() map[string][]Recording {
+	result := s.recorder.recordings()
+	for name, recordings := range s.saved {
+		if len(recordings) == 0 {
+			delete(result, name)
+		} else {
+			result[name] = recordings
+		}
+	}
+
+	return result
+}
+
+// Flush method writes generated files with saved recordings and removes
+// blob files, which are no longer referenced. When recordings are split into
+// multiple files, only files with saved tests are regenerated.
+func (s *GoStorage) Flush() error {
+	if err := s.flush(); err != nil {
+		return err
+	}
+
+	if s.check {
+		return nil
+	}
+
+	return removeUnusedBlobs(s.Dir)
+}
+
+func (s *GoStorage) flush() error {
+	recordings := s.recordings()
+
+	if !s.SplitFiles {
+		return s.generate(path.Join(s.Dir, s.Filename), recordings)
+	}
+
+	// generated filenames of all tests, tests that were not saved are kept
+	// in files they were loaded from
+	genFilenames := s.recorder.loadedFiles()
+
+	// only files with saved tests are regenerated
+	changedFilenames := map[string]bool{}
+
+	testFilenames := s.recorder.filenames()
+	for testName := range s.saved {
+		// test could have moved to another file, or could have been
+		// dropped, so file it was loaded from needs to be regenerated too
+		if genFilename, ok := genFilenames[testName]; ok {
+			changedFilenames[genFilename] = true
+		}
+
+		if testFilename, ok := testFilenames[testName]; ok {
+			genFilenames[testName] = RecordingFilename(testFilename)
+			changedFilenames[genFilenames[testName]] = true
+		}
+	}
+
+	sortedFilenames := make([]string, 0, len(changedFilenames))
+	for genFilename := range changedFilenames {
+		sortedFilenames = append(sortedFilenames, genFilename)
+	}
+	sort.Strings(sortedFilenames)
+
+	// for every changed filename generate recordings
+	for _, genFilename := range sortedFilenames {
+		fileRecordings := map[string][]Recording{}
+		for testName, testRecordings := range recordings {
+			if genFilenames[testName] == genFilename {
+				fileRecordings[testName] = testRecordings
+			}
+		}
+
+		if err := s.generate(path.Join(s.Dir, genFilename), fileRecordings); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// generate generates file with recordings. In check mode file is only
+// compared with recordings and in review mode changed recordings are written
+// to a pending file.
+func (s *GoStorage) generate(genFilePath string, recordings map[string][]Recording) error {
+	opts := GenOptions{
+		RecorderVar: s.RecorderVar,
+		Filter: func(map[string][]Recording) map[string][]Recording {
+			return recordings
+		},
+	}
+
+	if !s.check && !s.review {
+		return s.Generator.GenerateToFile(s.recorder, opts, genFilePath)
+	}
+
+	diff, err := s.Generator.Diff(s.recorder, opts, genFilePath)
+	if err != nil {
+		return err
+	}
+
+	if s.check {
+		if diff != "" {
+			s.diffs = append(s.diffs, diff)
+		}
+
+		if s.Generator.blobs != nil {
+			for _, blobPath := range s.Generator.missingBlobs() {
+				s.diffs = append(s.diffs, fmt.Sprintf("blob file %s referenced by %s is missing", blobPath, genFilePath))
+			}
+		}
+
+		return nil
+	}
+
+	// in review mode changed recordings are written to a pending file,
+	// which is reviewed with testparrot review command
+	pendingFilePath := genFilePath + PendingFileSuffix
+	if diff == "" {
+		if err := os.Remove(pendingFilePath); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+
+		return nil
+	}
+
+	if err := s.Generator.GenerateToFile(s.recorder, opts, pendingFilePath); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "testparrot: pending recordings written to %s, review them with testparrot review\n", pendingFilePath)
+
+	return nil
+}
@@ -0,0 +1,190 @@
+package testparrot
+
+import (
+	"io/ioutil"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// memStorage stores recordings in memory
+type memStorage struct {
+	recordings map[string][]Recording
+}
+
+func (s *memStorage) Load(name string) ([]Recording, error) {
+	return s.recordings[name], nil
+}
+
+func (s *memStorage) Save(name string, recordings []Recording) error {
+	if len(recordings) == 0 {
+		delete(s.recordings, name)
+	} else {
+		s.recordings[name] = recordings
+	}
+
+	return nil
+}
+
+func (s *memStorage) List() ([]string, error) {
+	names := []string{}
+	for name := range s.recordings {
+		names = append(names, name)
+	}
+
+	return names, nil
+}
+
+func TestRecorderStorage(t *testing.T) {
+	defineTestparrotFlags()
+
+	storage := &memStorage{recordings: map[string][]Recording{
+		t.Name():    {{"key", "value"}},
+		"TestOther": {{"key", "value"}},
+		"TestGone":  {{"key", "value"}},
+	}}
+
+	recorder := NewRecorder()
+	recorder.SetStorage(storage)
+	require.Equal(t, storage, recorder.Storage())
+
+	require.Equal(t, "value", recorder.Record(t, "key", nil))
+
+	recorder.EnableRecording(true)
+	t.Run("sub", func(t *testing.T) {
+		recorder.RecordNext(t, "new")
+	})
+
+	require.NoError(t, recorder.loadAllStorage())
+	recorder.drop([]string{"TestGone"})
+
+	diffs, err := saveRecordings(recorder, storage)
+	require.NoError(t, err)
+	require.Nil(t, diffs)
+	require.Equal(t, map[string][]Recording{
+		t.Name():          {{"key", "value"}},
+		t.Name() + "/sub": {{0, "new"}},
+		"TestOther":       {{"key", "value"}},
+	}, storage.recordings)
+}
+
+func TestGoStorage(t *testing.T) {
+	dir := t.TempDir()
+
+	recorder := NewRecorder()
+	recorder.Load("TestA", []Recording{{"key", "value"}})
+
+	storage := NewGoStorage(recorder, NewGenerator("my/go-pkg", "pkg"), dir)
+	storage.RecorderVar = "recorder"
+	require.Equal(t, "pkg_recording_test.go", storage.Filename)
+
+	recordings, err := storage.Load("TestA")
+	require.NoError(t, err)
+	require.Nil(t, recordings)
+
+	require.NoError(t, storage.Save("TestB", []Recording{{0, "value"}}))
+	require.NoError(t, storage.Flush())
+
+	src, err := ioutil.ReadFile(path.Join(dir, "pkg_recording_test.go"))
+	require.NoError(t, err)
+	require.Contains(t, string(src), `recorder.Load("TestA"`)
+	require.Contains(t, string(src), `recorder.Load("TestB"`)
+
+	names, err := storage.List()
+	require.NoError(t, err)
+	require.Equal(t, []string{"TestA", "TestB"}, names)
+
+	// saving empty recordings removes test
+	require.NoError(t, storage.Save("TestA", nil))
+	require.NoError(t, storage.Flush())
+
+	names, err = storage.List()
+	require.NoError(t, err)
+	require.Equal(t, []string{"TestB"}, names)
+}
+
+// checkedMemStorage stores recordings in memory and supports check mode
+type checkedMemStorage struct {
+	memStorage
+	check bool
+	diffs []string
+}
+
+func (s *checkedMemStorage) Save(name string, recordings []Recording) error {
+	if s.check {
+		s.diffs = append(s.diffs, name)
+		return nil
+	}
+
+	return s.memStorage.Save(name, recordings)
+}
+
+func (s *checkedMemStorage) SetCheck(check bool) {
+	s.check = check
+}
+
+func (s *checkedMemStorage) CheckDiffs() []string {
+	return s.diffs
+}
+
+// reviewedMemStorage stores recordings in memory and supports review mode
+type reviewedMemStorage struct {
+	memStorage
+	review bool
+}
+
+func (s *reviewedMemStorage) SetReview(review bool) {
+	s.review = review
+}
+
+func TestSaveRecordingsModes(t *testing.T) {
+	defineTestparrotFlags()
+
+	newRecorder := func(t *testing.T) *Recorder {
+		recorder := NewRecorder()
+		recorder.EnableRecording(true)
+		recorder.RecordNext(t, "value")
+		return recorder
+	}
+
+	t.Run("check mode", func(t *testing.T) {
+		*checkFlag = true
+		defer func() { *checkFlag = false }()
+
+		storage := &checkedMemStorage{memStorage: memStorage{recordings: map[string][]Recording{}}}
+		diffs, err := saveRecordings(newRecorder(t), storage)
+		require.NoError(t, err)
+		require.Equal(t, []string{t.Name()}, diffs)
+		require.Empty(t, storage.recordings)
+	})
+
+	t.Run("check mode not supported", func(t *testing.T) {
+		*checkFlag = true
+		defer func() { *checkFlag = false }()
+
+		_, err := saveRecordings(newRecorder(t), &memStorage{recordings: map[string][]Recording{}})
+		require.EqualError(t, err, "testparrot: check mode is not supported by storage *testparrot.memStorage")
+	})
+
+	t.Run("review mode", func(t *testing.T) {
+		*reviewFlag = true
+		defer func() { *reviewFlag = false }()
+
+		storage := &reviewedMemStorage{memStorage: memStorage{recordings: map[string][]Recording{}}}
+		recorder := newRecorder(t)
+		recorder.SetStorage(storage)
+
+		_, err := saveRecordings(recorder, newStorage(recorder, "recorder", 0))
+		require.NoError(t, err)
+		require.True(t, storage.review)
+	})
+
+	t.Run("review mode not supported", func(t *testing.T) {
+		*reviewFlag = true
+		defer func() { *reviewFlag = false }()
+
+		_, err := saveRecordings(newRecorder(t), &memStorage{recordings: map[string][]Recording{}})
+		require.EqualError(t, err, "testparrot: review mode is not supported by storage *testparrot.memStorage")
+	})
+}
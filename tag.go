@@ -0,0 +1,77 @@
+package testparrot
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// tagName defines name of struct tag with testparrot options
+const tagName = "testparrot"
+
+// tagOptions defines options of a struct field, set with testparrot struct
+// tag, like `testparrot:"approx=0.01"`. Multiple options are separated by
+// commas.
+type tagOptions struct {
+	// omit defines whether field is never recorded, set with "-"
+	omit bool
+
+	// keepZero defines whether field is generated even when it is zero
+	keepZero bool
+
+	// redact defines whether value of field is replaced with a placeholder
+	redact bool
+
+	// ignore defines whether field is ignored when comparing values
+	ignore bool
+
+	// tolerance defines tolerance of floats, if hasTolerance is set
+	tolerance    float64
+	hasTolerance bool
+
+	// unordered defines whether slices are compared as unordered sets
+	unordered bool
+
+	// extraKeys defines whether extra keys of actual maps are ignored
+	extraKeys bool
+}
+
+// parseTag parses testparrot tag of struct field. Unknown options are
+// ignored.
+func parseTag(field reflect.StructField) (tagOptions, error) {
+	opts := tagOptions{}
+
+	tag, ok := field.Tag.Lookup(tagName)
+	if !ok {
+		return opts, nil
+	}
+
+	for _, option := range strings.Split(tag, ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(option), "=")
+
+		switch name {
+		case "-":
+			opts.omit = true
+		case "keepzero":
+			opts.keepZero = true
+		case "redact":
+			opts.redact = true
+		case "ignore":
+			opts.ignore = true
+		case "approx":
+			tolerance, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return opts, fmt.Errorf("invalid approx option of field '%s': %v", field.Name, err)
+			}
+
+			opts.tolerance, opts.hasTolerance = tolerance, true
+		case "unordered":
+			opts.unordered = true
+		case "extrakeys":
+			opts.extraKeys = true
+		}
+	}
+
+	return opts, nil
+}
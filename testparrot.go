@@ -1,5 +1,11 @@
 package testparrot
 
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
 // R defines a global test recorder
 var R = NewRecorder()
 
@@ -12,3 +18,79 @@ var Must = must
 var Record = R.Record
 
 var RecordNext = R.RecordNext
+
+var RecordNextIn = R.RecordNextIn
+
+var Expect = R.Expect
+
+var ExpectNext = R.ExpectNext
+
+var ExpectInline = R.ExpectInline
+
+// Value records value of type T under specified key using global recorder.
+// If recording is enabled Value returns provided value, otherwise it returns
+// already recorded value. Test fails if recorded value is not of type T.
+func Value[T any](t testing.TB, key interface{}, value T) T {
+	t.Helper()
+
+	recorded, err := R.recordKey(t, key, value)
+	if err != nil {
+		R.fail(t, err)
+
+		var zero T
+		return zero
+	}
+
+	result, err := castRecording[T](t.Name(), key, recorded)
+	if err != nil {
+		R.fail(t, err)
+	}
+
+	return result
+}
+
+// Next records next value of type T in sequence using global recorder. If
+// recording is enabled Next returns provided value, otherwise it returns
+// already recorded value. Test fails if recorded value is not of type T.
+func Next[T any](t testing.TB, value T) T {
+	t.Helper()
+
+	key, recorded, err := R.recordNext(t, value)
+	if err != nil {
+		R.fail(t, err)
+
+		var zero T
+		return zero
+	}
+
+	result, err := castRecording[T](t.Name(), key, recorded)
+	if err != nil {
+		R.fail(t, err)
+	}
+
+	return result
+}
+
+// castRecording converts recorded value to type T
+func castRecording[T any](name string, key interface{}, value interface{}) (T, error) {
+	var result T
+
+	typ := reflect.TypeOf((*T)(nil)).Elem()
+
+	// nil is a valid recording for types like pointers, slices and maps
+	if value == nil {
+		switch typ.Kind() {
+		case reflect.Ptr, reflect.Slice, reflect.Map, reflect.Interface, reflect.Func, reflect.Chan:
+			return result, nil
+		}
+	}
+
+	result, ok := value.(T)
+	if !ok {
+		return result, newErr(fmt.Errorf(
+			"recording with key '%v' for test '%s' has type '%T', expected '%v'",
+			key, name, value, typ))
+	}
+
+	return result, nil
+}
@@ -0,0 +1,62 @@
+package testparrot
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// loadGlobal loads recordings of a test into global recorder and removes
+// them when test finishes, so test can run multiple times
+func loadGlobal(t *testing.T, recordings []Recording) {
+	R.Load(t.Name(), recordings)
+	t.Cleanup(func() { R.drop([]string{t.Name()}) })
+}
+
+func TestValue(t *testing.T) {
+	t.Run("replay values", func(t *testing.T) {
+		loadGlobal(t, []Recording{{"key", testStruct{V1: "value"}}})
+		require.Equal(t, testStruct{V1: "value"}, Value(t, "key", testStruct{}))
+	})
+
+	t.Run("replay nil pointer", func(t *testing.T) {
+		loadGlobal(t, []Recording{{"key", nil}})
+		require.Nil(t, Value(t, "key", &testStruct{}))
+	})
+}
+
+func TestValueTypeMismatch(t *testing.T) {
+	loadGlobal(t, []Recording{{"key", "value"}})
+
+	ft := &fakeTB{TB: t}
+	require.Equal(t, 0, Value(ft, "key", 0))
+	require.Equal(t, []string{
+		"testparrot: recording with key 'key' for test 'TestValueTypeMismatch' has type 'string', expected 'int'",
+	}, ft.failures)
+}
+
+func TestNext(t *testing.T) {
+	loadGlobal(t, []Recording{{0, "value1"}, {1, 2}})
+	require.Equal(t, "value1", Next(t, ""))
+	require.Equal(t, 2, Next(t, 0))
+}
+
+func TestCastRecording(t *testing.T) {
+	t.Run("matching type", func(t *testing.T) {
+		v, err := castRecording[string]("test", "key", "value")
+		require.NoError(t, err)
+		require.Equal(t, "value", v)
+	})
+
+	t.Run("type mismatch", func(t *testing.T) {
+		_, err := castRecording[int]("test", "key", "value")
+		require.EqualError(t, err,
+			"testparrot: recording with key 'key' for test 'test' has type 'string', expected 'int'")
+	})
+
+	t.Run("nil for non nillable type", func(t *testing.T) {
+		_, err := castRecording[testStruct]("test", 0, nil)
+		require.EqualError(t, err,
+			"testparrot: recording with key '0' for test 'test' has type '<nil>', expected 'testparrot.testStruct'")
+	})
+}
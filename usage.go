@@ -0,0 +1,57 @@
+package testparrot
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"sort"
+)
+
+// TestUsage defines how loaded recordings of a test were used in a test run.
+// Usage is written to a file when tests run with -testparrot.usagefile flag.
+type TestUsage struct {
+	// Test defines name of a test
+	Test string `json:"test"`
+
+	// Used defines whether test used any of its recordings
+	Used bool `json:"used"`
+
+	// Unused defines indexes of recordings that were not used, in order in
+	// which recordings were loaded
+	Unused []int `json:"unused,omitempty"`
+}
+
+// usage returns usage of recordings of all loaded tests, sorted by test name
+func (r *Recorder) usage() []TestUsage {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	result := []TestUsage{}
+	for name, recordings := range r.allRecordings {
+		used, ok := r.usedKeys[name]
+		usage := TestUsage{Test: name, Used: ok}
+
+		for i, recording := range recordings {
+			if !used[recording.Key] {
+				usage.Unused = append(usage.Unused, i)
+			}
+		}
+
+		result = append(result, usage)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Test < result[j].Test
+	})
+
+	return result
+}
+
+// writeUsage writes usage of recordings to a file as JSON
+func writeUsage(recorder *Recorder, filename string) error {
+	data, err := json.MarshalIndent(recorder.usage(), "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(filename, data, 0660)
+}
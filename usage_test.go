@@ -0,0 +1,39 @@
+package testparrot
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecorderUsage(t *testing.T) {
+	recorder := NewRecorder()
+	recorder.Load(t.Name(), []Recording{{"key1", "value1"}, {"key2", "value2"}, {"key3", "value3"}})
+	recorder.Load("TestOther", []Recording{{0, "value"}})
+
+	recorder.Record(t, "key2", nil)
+
+	require.Equal(t, []TestUsage{
+		{Test: "TestOther", Used: false, Unused: []int{0}},
+		{Test: t.Name(), Used: true, Unused: []int{0, 2}},
+	}, recorder.usage())
+}
+
+func TestWriteUsage(t *testing.T) {
+	recorder := NewRecorder()
+	recorder.Load(t.Name(), []Recording{{"key", "value"}})
+	recorder.Record(t, "key", nil)
+
+	filename := filepath.Join(t.TempDir(), "usage.json")
+	require.NoError(t, writeUsage(recorder, filename))
+
+	data, err := ioutil.ReadFile(filename)
+	require.NoError(t, err)
+
+	usage := []TestUsage{}
+	require.NoError(t, json.Unmarshal(data, &usage))
+	require.Equal(t, []TestUsage{{Test: t.Name(), Used: true}}, usage)
+}
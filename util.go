@@ -2,6 +2,7 @@ package testparrot
 
 import (
 	"encoding"
+	"encoding/gob"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -13,6 +14,8 @@ import (
 	"runtime"
 	"strings"
 	"testing"
+
+	"github.com/xtruder/go-testparrot/internal/testdecl"
 )
 
 // current package name and path, we need those when generating, so we can
@@ -63,6 +66,8 @@ func Decode(data interface{}, target interface{}) interface{} {
 		panicOnErr(v.UnmarshalJSON(bytes))
 	case encoding.BinaryUnmarshaler:
 		panicOnErr(v.UnmarshalBinary(bytes))
+	case gob.GobDecoder:
+		panicOnErr(v.GobDecode(bytes))
 	default:
 		panic(fmt.Sprintf("unsupported type to decode %T", target))
 	}
@@ -81,8 +86,9 @@ func valToPtr(val interface{}) interface{} {
 	return p.Interface()
 }
 
-// getTestPath method walks up the stack and tries to get path of file where test is in
-func getTestPath(t *testing.T) (string, error) {
+// getTestPath method walks up the stack and tries to get path of file where
+// test, benchmark or fuzz target is in
+func getTestPath(t testing.TB) (string, error) {
 	skip := 0
 
 	// use only what is before slash in test name
@@ -99,9 +105,8 @@ func getTestPath(t *testing.T) (string, error) {
 
 		funcName = funcName[(firstDot + 1):]
 
-		// we assume name of the test name prefixes function name and that
-		// file has _test.go suffix
-		if strings.HasPrefix(funcName, testName) && strings.HasSuffix(path, "_test.go") {
+		// we assume that file has _test.go suffix
+		if isTestFunc(funcName, testName) && strings.HasSuffix(path, "_test.go") {
 			return path, nil
 		}
 
@@ -111,6 +116,44 @@ func getTestPath(t *testing.T) (string, error) {
 	return "", fmt.Errorf("test filename not found for: %s", t.Name())
 }
 
+// getCallSite method walks up the stack and returns location of the first
+// call from a test file, together with package path of the test
+func getCallSite() (filename string, line int, pkgPath string, err error) {
+	for skip := 1; ; skip++ {
+		pc, path, line, ok := runtime.Caller(skip)
+		if !ok {
+			break
+		}
+
+		// we assume that file has _test.go suffix
+		if !strings.HasSuffix(path, "_test.go") {
+			continue
+		}
+
+		funcName := runtime.FuncForPC(pc).Name()
+		lastSlash := strings.LastIndexByte(funcName, '/')
+		if lastSlash < 0 {
+			lastSlash = 0
+		}
+		firstDot := strings.IndexByte(funcName[lastSlash:], '.') + lastSlash
+
+		return path, line, funcName[:firstDot], nil
+	}
+
+	return "", 0, "", errors.New("call site in test file not found")
+}
+
+// isTestFunc checks whether function name without package path belongs to a
+// top level test, benchmark or fuzz target. Function name is either equal to
+// test name, or is a closure defined in a test function, like TestXxx.func1.
+func isTestFunc(funcName string, testName string) bool {
+	if !testdecl.IsTestName(testName) {
+		return false
+	}
+
+	return funcName == testName || strings.HasPrefix(funcName, testName+".")
+}
+
 // getPkgInfo gets package path, name and fs location of current package
 func getPkgInfo(skip int, pkgNameFromSource bool) (pkgPath string, pkgName string, fsPath string, err error) {
 	pc, filename, _, ok := runtime.Caller(skip + 1)
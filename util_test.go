@@ -79,3 +79,49 @@ func TestGetTestPath(t *testing.T) {
 		})
 	})
 }
+
+func TestIsTestFunc(t *testing.T) {
+	tests := []struct {
+		funcName string
+		testName string
+		expected bool
+	}{
+		{"TestGetTestPath", "TestGetTestPath", true},
+		{"TestGetTestPath.func1.1", "TestGetTestPath", true},
+		{"BenchmarkRecord", "BenchmarkRecord", true},
+		{"BenchmarkRecord.func1", "BenchmarkRecord", true},
+		{"FuzzRecord", "FuzzRecord", true},
+		{"FuzzRecord.func1", "FuzzRecord", true},
+		{"Test", "Test", true},
+		{"Test_underscore", "Test_underscore", true},
+		{"TestGetTestPathHelper", "TestGetTestPath", false},
+		{"Testing", "Testing", false},
+		{"Fuzzy.func1", "Fuzzy", false},
+		{"helper", "", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.funcName, func(t *testing.T) {
+			require.Equal(t, test.expected, isTestFunc(test.funcName, test.testName))
+		})
+	}
+}
+
+func BenchmarkGetTestPath(b *testing.B) {
+	filename, err := getTestPath(b)
+	require.NoError(b, err)
+	require.Equal(b, "util_test.go", path.Base(filename))
+}
+
+func FuzzGetTestPath(f *testing.F) {
+	filename, err := getTestPath(f)
+	require.NoError(f, err)
+	require.Equal(f, "util_test.go", path.Base(filename))
+
+	f.Add("seed")
+	f.Fuzz(func(t *testing.T, _ string) {
+		filename, err := getTestPath(t)
+		require.NoError(t, err)
+		require.Equal(t, "util_test.go", path.Base(filename))
+	})
+}